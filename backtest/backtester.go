@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/quick-trade/xoney/common"
 	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/events"
 	"github.com/quick-trade/xoney/exchange"
 	"github.com/quick-trade/xoney/internal"
 	exec "github.com/quick-trade/xoney/internal/executing"
@@ -15,26 +17,93 @@ type StepByStepBacktester struct {
 	system    st.Tradable
 	equity    data.Equity
 	simulator exchange.Simulator
+	latency   LatencyModel
+	pending   eventQueue
+	execution map[data.Symbol][]data.InstrumentCandle // Candles fed to the simulator instead of the strategy ones.
+	cursors   map[data.Symbol]int                     // Index of the first execution candle not yet fed.
+	ids       common.IDGenerator                      // Generator of IDs restarted at every start, nil to keep the current one.
 }
 
-func NewStepByStepBacktester(simulator exchange.Simulator) *StepByStepBacktester {
-	return &StepByStepBacktester{
+// Option configures a StepByStepBacktester.
+type Option func(*StepByStepBacktester)
+
+// WithLatency delays the events produced by the trading system: they reach
+// the simulator only after the delay drawn from the model has passed in
+// simulated time. Delayed market orders are executed at the market price at
+// the moment of arrival. Events produced in response to order updates and
+// fills are processed immediately.
+func WithLatency(model LatencyModel) Option {
+	return func(b *StepByStepBacktester) {
+		b.latency = model
+	}
+}
+
+// WithExecutionCharts feeds the simulator with candles of a finer timeframe
+// than the ones passed to the trading system, e.g. 1m candles for a strategy
+// working on 1h candles. The simulator receives the execution candles of the
+// symbol that closed up to the close of every strategy candle; symbols without
+// execution candles are simulated by the strategy candles. If a symbol has
+// several charts, the one with the smallest timeframe is used.
+func WithExecutionCharts(charts data.ChartContainer) Option {
+	return func(b *StepByStepBacktester) {
+		b.execution = executionCandles(charts)
+	}
+}
+
+// WithIDGenerator makes the IDs of orders and grid levels created during the
+// backtest reproducible: the generator is restarted at every start of the backtest
+// and installed with common.UseIDGenerator during every step, so that the same input
+// produces the same trade journal. The previous generator is restored after each step.
+// The generator is process-wide, so the journal is reproducible only if no orders
+// are created concurrently with the backtest, e.g. by other backtests.
+func WithIDGenerator(generator common.IDGenerator) Option {
+	return func(b *StepByStepBacktester) {
+		b.ids = generator
+	}
+}
+
+func NewStepByStepBacktester(simulator exchange.Simulator, options ...Option) *StepByStepBacktester {
+	backtester := &StepByStepBacktester{
 		system:	   nil,
 		equity:    data.Equity{},
 		simulator: simulator,
+		pending:   make(eventQueue, 0, internal.DefaultCapacity),
+		execution: make(map[data.Symbol][]data.InstrumentCandle),
+		cursors:   make(map[data.Symbol]int),
 	}
+
+	for _, option := range options {
+		option(backtester)
+	}
+
+	return backtester
 }
 
 func (b *StepByStepBacktester) Start(charts data.ChartContainer, system st.Tradable) error {
-	err := b.setup(charts, system)
-	if err != nil {
-		return fmt.Errorf("error during backtest setup: %w", err)
-	}
+	return b.withIDs(func() error {
+		err := b.setup(charts, system)
+		if err != nil {
+			return fmt.Errorf("error during backtest setup: %w", err)
+		}
 
-	return nil
+		return nil
+	})
 }
 
 func (b *StepByStepBacktester) Next(candle data.InstrumentCandle) error {
+	return b.withIDs(func() error { return b.next(candle) })
+}
+
+// withIDs runs the step with the generator of IDs of the backtest, if any.
+func (b *StepByStepBacktester) withIDs(step func() error) error {
+	if b.ids == nil {
+		return step()
+	}
+
+	return common.UseIDGenerator(b.ids, step)
+}
+
+func (b *StepByStepBacktester) next(candle data.InstrumentCandle) error {
 	if err := b.updatePrices(candle); err != nil {
 		return err
 	}
@@ -44,22 +113,35 @@ func (b *StepByStepBacktester) Next(candle data.InstrumentCandle) error {
 		return err
 	}
 
+	if err := exec.Notify(b.simulator, b.system); err != nil {
+		return err
+	}
+
 	event, err := b.system.Next(candle)
 	if err != nil {
 		return err
 	}
 
-	if err = exec.ProcessEvent(b.simulator, event); err != nil {
+	sendErr := b.send(event, candle.TimeClose)
+
+	// Orders executed or rejected immediately are reported before the next candle.
+	if err := exec.Notify(b.simulator, b.system); err != nil {
 		return err
 	}
 
-	return nil
+	return sendErr
 }
 
 func (b *StepByStepBacktester) GetEquity() data.Equity {
 	return b.equity
 }
 
+// Journal returns the trade journal: all executions of orders
+// since the start of the backtest.
+func (b *StepByStepBacktester) Journal() []exchange.Fill {
+	return b.simulator.Fills()
+}
+
 func (b *StepByStepBacktester) setup(
 	charts data.ChartContainer,
 	system st.Tradable,
@@ -71,6 +153,17 @@ func (b *StepByStepBacktester) setup(
 
 	b.system = system
 
+	b.pending = b.pending[:0]
+	b.cursors = make(map[data.Symbol]int, len(b.execution))
+
+	if model, ok := b.latency.(resettable); ok {
+		model.reset()
+	}
+
+	if b.ids != nil {
+		b.ids.Reset()
+	}
+
 	b.equity = *generateStartEquity(charts)
 
 	durations := system.MinDurations()
@@ -91,8 +184,108 @@ func (b *StepByStepBacktester) cleanup() error {
 	return nil
 }
 
+// updatePrices feeds the simulator with the execution candles of the candle
+// symbol and delivers the pending events that arrive before each of them.
 func (b *StepByStepBacktester) updatePrices(candle data.InstrumentCandle) error {
-	return b.simulator.UpdatePrice(candle)
+	for _, step := range b.executionSteps(candle) {
+		open := step.TimeClose.Add(-step.Timeframe().Duration)
+		if err := b.deliver(open); err != nil {
+			return err
+		}
+
+		if err := b.simulator.UpdatePrice(step); err != nil {
+			return err
+		}
+	}
+
+	return b.deliver(candle.TimeClose)
+}
+
+// executionSteps returns the candles that have to be fed
+// to the simulator before the system receives the candle.
+func (b *StepByStepBacktester) executionSteps(candle data.InstrumentCandle) []data.InstrumentCandle {
+	symbol := candle.Symbol()
+
+	candles, ok := b.execution[symbol]
+	if !ok {
+		return []data.InstrumentCandle{candle}
+	}
+
+	start := b.cursors[symbol]
+	stop := start
+
+	for stop < len(candles) && !candles[stop].TimeClose.After(candle.TimeClose) {
+		stop++
+	}
+
+	b.cursors[symbol] = stop
+
+	return candles[start:stop]
+}
+
+// send passes the event to the simulator or, if latency is simulated,
+// puts it in the queue of pending events.
+func (b *StepByStepBacktester) send(event events.Event, moment time.Time) error {
+	if b.latency == nil {
+		return skipRejection(exec.ProcessEvent(b.simulator, event))
+	}
+
+	if event == nil {
+		return nil
+	}
+
+	b.pending.push(moment.Add(b.latency.Delay()), event)
+
+	return b.deliver(moment)
+}
+
+// deliver processes the pending events that have arrived by the moment.
+func (b *StepByStepBacktester) deliver(moment time.Time) error {
+	connector := delayedConnector{Simulator: b.simulator}
+
+	for _, event := range b.pending.pop(moment) {
+		if err := skipRejection(exec.ProcessEvent(connector, event)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// skipRejection drops the rejections of orders: they are reported to the
+// system as order updates and do not stop the backtest.
+func skipRejection(err error) error {
+	if exec.IsRejection(err) {
+		return nil
+	}
+
+	return err
+}
+
+func executionCandles(charts data.ChartContainer) map[data.Symbol][]data.InstrumentCandle {
+	result := make(map[data.Symbol][]data.InstrumentCandle, len(charts))
+	timeframes := make(map[data.Symbol]time.Duration, len(charts))
+
+	for instrument, chart := range charts {
+		symbol := instrument.Symbol()
+		duration := instrument.Timeframe().Duration
+
+		if known, ok := timeframes[symbol]; ok && known <= duration {
+			continue
+		}
+
+		candles := make([]data.InstrumentCandle, 0, chart.Len())
+
+		for index := 0; index < chart.Len(); index++ {
+			candle, _ := chart.CandleByIndex(index)
+			candles = internal.Append(candles, *data.NewInstrumentCandle(*candle, instrument))
+		}
+
+		result[symbol] = candles
+		timeframes[symbol] = duration
+	}
+
+	return result
 }
 
 func (b *StepByStepBacktester) updateBalance(timestamp time.Time) error {
@@ -105,16 +298,39 @@ func (b *StepByStepBacktester) updateBalance(timestamp time.Time) error {
 
 	b.equity.AddPortfolio(b.simulator.Portfolio().Assets())
 
+	if reporter, ok := b.simulator.(costReporter); ok {
+		b.equity.AddCosts(costsByKind(reporter.Costs()))
+	}
+
 	return nil
 }
 
+// costReporter is implemented by simulators that account their trading costs.
+type costReporter interface {
+	Costs() exchange.Costs
+}
+
+func costsByKind(costs exchange.Costs) map[string]float64 {
+	result := make(map[string]float64, len(costs))
+	for kind, cost := range costs {
+		result[string(kind)] = cost
+	}
+
+	return result
+}
+
 type Backtester struct {
 	simulator exchange.Simulator
+	journal   []exchange.Fill
+	options   []Option
 }
 
-func NewBacktester(simulator exchange.Simulator) *Backtester {
+// NewBacktester creates a Backtester, the options
+// configure the StepByStepBacktester used for every test.
+func NewBacktester(simulator exchange.Simulator, options ...Option) *Backtester {
 	return &Backtester{
 		simulator: simulator,
+		options:   options,
 	}
 }
 
@@ -122,6 +338,8 @@ func (b *Backtester) Backtest(
 	charts data.ChartContainer,
 	system st.Tradable,
 ) (data.Equity, error) {
+	defer func() { b.journal = b.simulator.Fills() }()
+
 	if vecTradable, ok := system.(st.VectorizedTradable); ok {
 		return vecTradable.Backtest(b.simulator, charts)
 	}
@@ -131,20 +349,30 @@ func (b *Backtester) Backtest(
 		return equity, fmt.Errorf("error during backtest: %w", err)
 	}
 
-	return data.Equity{}, nil
+	return equity, nil
+}
+
+// Journal returns the trade journal of the last backtest:
+// all executions of orders in the order they occurred.
+func (b *Backtester) Journal() []exchange.Fill {
+	return b.journal
 }
 
 func (b *Backtester) runTest(
 	charts data.ChartContainer,
 	system st.Tradable,
 ) (data.Equity, error) {
-	bt := NewStepByStepBacktester(b.simulator)
+	bt := NewStepByStepBacktester(b.simulator, b.options...)
 
 	startCharts := firstByDuration(charts, system.MinDurations().Max())
-	bt.Start(startCharts, system)
+	if err := bt.Start(startCharts, system); err != nil {
+		return bt.GetEquity(), err
+	}
 
 	for _, candle := range charts.Candles() {
-		bt.Next(candle)
+		if err := bt.Next(candle); err != nil {
+			return bt.GetEquity(), fmt.Errorf("error processing candle at %v: %w", candle.TimeClose, err)
+		}
 	}
 
 	return bt.GetEquity(), nil
@@ -0,0 +1,218 @@
+package backtest
+
+import (
+	"math"
+
+	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/internal"
+)
+
+// Benchmark is a series of values the equity is compared with,
+// e.g. the equity of another strategy or buy-and-hold of the traded asset.
+type Benchmark struct {
+	values    []float64
+	timestamp data.TimeStamp
+}
+
+// NewEquityBenchmark creates a Benchmark from the equity of another backtest.
+func NewEquityBenchmark(equity data.Equity) Benchmark {
+	return Benchmark{values: equity.Deposit(), timestamp: equity.Timestamp}
+}
+
+// NewChartBenchmark creates a Benchmark from the closing prices of the chart,
+// which corresponds to buying and holding the instrument.
+func NewChartBenchmark(chart data.Chart) Benchmark {
+	return Benchmark{values: chart.Close, timestamp: chart.Timestamp}
+}
+
+// returns calculates the simple returns of the equity and of the benchmark
+// between the moments of the equity. The benchmark is taken at its last moment
+// that is not after the moment of the equity; the moments of the equity before
+// the start of the benchmark are skipped.
+func (b Benchmark) returns(equity data.Equity) (internal.Data, internal.Data) {
+	deposit := equity.Deposit()
+
+	strategy := make(internal.Data, 0, len(deposit))
+	benchmark := make(internal.Data, 0, len(deposit))
+
+	for i, value := range deposit {
+		index, err := b.timestamp.IndexBeforeOrAt(equity.Timestamp.At(i))
+		if err != nil {
+			continue
+		}
+
+		strategy = internal.Append(strategy, value)
+		benchmark = internal.Append(benchmark, b.values[index])
+	}
+
+	return internal.Returns(strategy), internal.Returns(benchmark)
+}
+
+// relativeMoments describes the returns of the equity relative to the benchmark.
+type relativeMoments struct {
+	strategyMean  float64
+	benchmarkMean float64
+	covariance    float64
+	strategyVar   float64
+	benchmarkVar  float64
+}
+
+func newRelativeMoments(strategy, benchmark internal.Data) (relativeMoments, error) {
+	strategyMean, err := internal.RawMoment(strategy, 1)
+	if err != nil {
+		return relativeMoments{}, err
+	}
+
+	benchmarkMean, _ := internal.RawMoment(benchmark, 1)
+
+	covariance := 0.0
+	for i := range strategy {
+		covariance += (strategy[i] - strategyMean) * (benchmark[i] - benchmarkMean)
+	}
+
+	return relativeMoments{
+		strategyMean:  strategyMean,
+		benchmarkMean: benchmarkMean,
+		covariance:    covariance / float64(len(strategy)),
+		strategyVar:   internal.CentralMoment(strategy, strategyMean, 2),
+		benchmarkVar:  internal.CentralMoment(benchmark, benchmarkMean, 2),
+	}, nil
+}
+
+func (r relativeMoments) beta() float64 { return r.covariance / r.benchmarkVar }
+
+// Beta is the sensitivity of the returns of the equity to the returns of the benchmark.
+type Beta struct {
+	Benchmark Benchmark
+}
+
+func (b Beta) Evaluate(equity data.Equity) float64 {
+	moments, err := newRelativeMoments(b.Benchmark.returns(equity))
+	if err != nil {
+		return 0
+	}
+
+	return moments.beta()
+}
+
+// Alpha is Jensen's alpha: the annualized return of the equity in excess of
+// the return predicted by its beta to the benchmark.
+type Alpha struct {
+	Benchmark Benchmark
+	RF        float64 // Annual risk-free rate.
+}
+
+func (a Alpha) Evaluate(equity data.Equity) float64 {
+	moments, err := newRelativeMoments(a.Benchmark.returns(equity))
+	if err != nil {
+		return 0
+	}
+
+	inYear := equity.Timeframe().CandlesPerYear
+
+	return moments.strategyMean*inYear - a.RF - moments.beta()*(moments.benchmarkMean*inYear-a.RF)
+}
+
+// Correlation is the correlation coefficient of the returns of the equity and of the benchmark.
+type Correlation struct {
+	Benchmark Benchmark
+}
+
+func (c Correlation) Evaluate(equity data.Equity) float64 {
+	moments, err := newRelativeMoments(c.Benchmark.returns(equity))
+	if err != nil {
+		return 0
+	}
+
+	return moments.covariance / math.Sqrt(moments.strategyVar*moments.benchmarkVar)
+}
+
+// activeReturns returns the differences between the returns of the equity and of the benchmark.
+func activeReturns(strategy, benchmark internal.Data) internal.Data {
+	active := make(internal.Data, len(strategy))
+	for i := range strategy {
+		active[i] = strategy[i] - benchmark[i]
+	}
+
+	return active
+}
+
+// TrackingError is the annualized standard deviation of the differences
+// between the returns of the equity and of the benchmark.
+type TrackingError struct {
+	Benchmark Benchmark
+}
+
+func (t TrackingError) Evaluate(equity data.Equity) float64 {
+	active := activeReturns(t.Benchmark.returns(equity))
+
+	mean, err := internal.RawMoment(active, 1)
+	if err != nil {
+		return 0
+	}
+
+	return math.Sqrt(internal.CentralMoment(active, mean, 2) * equity.Timeframe().CandlesPerYear)
+}
+
+// InformationRatio is the annualized return of the equity in excess of
+// the benchmark divided by the tracking error.
+type InformationRatio struct {
+	Benchmark Benchmark
+}
+
+func (i InformationRatio) Evaluate(equity data.Equity) float64 {
+	active := activeReturns(i.Benchmark.returns(equity))
+
+	mean, err := internal.RawMoment(active, 1)
+	if err != nil {
+		return 0
+	}
+
+	inYear := equity.Timeframe().CandlesPerYear
+
+	return mean * inYear / math.Sqrt(internal.CentralMoment(active, mean, 2)*inYear)
+}
+
+// capture returns the ratio of the mean returns of the equity and of the
+// benchmark over the periods selected by the returns of the benchmark.
+func capture(strategy, benchmark internal.Data, selected func(float64) bool) float64 {
+	strategySum, benchmarkSum := 0.0, 0.0
+
+	for i := range benchmark {
+		if selected(benchmark[i]) {
+			strategySum += strategy[i]
+			benchmarkSum += benchmark[i]
+		}
+	}
+
+	if benchmarkSum == 0 {
+		return 0
+	}
+
+	return strategySum / benchmarkSum
+}
+
+// UpCapture is the mean return of the equity during the periods of positive
+// returns of the benchmark relative to the mean return of the benchmark.
+type UpCapture struct {
+	Benchmark Benchmark
+}
+
+func (u UpCapture) Evaluate(equity data.Equity) float64 {
+	strategy, benchmark := u.Benchmark.returns(equity)
+
+	return capture(strategy, benchmark, func(value float64) bool { return value > 0 })
+}
+
+// DownCapture is the mean return of the equity during the periods of negative
+// returns of the benchmark relative to the mean return of the benchmark.
+// Values below one mean that the equity loses less than the benchmark.
+type DownCapture struct {
+	Benchmark Benchmark
+}
+
+func (d DownCapture) Evaluate(equity data.Equity) float64 {
+	strategy, benchmark := d.Benchmark.returns(equity)
+
+	return capture(strategy, benchmark, func(value float64) bool { return value < 0 })
+}
@@ -0,0 +1,147 @@
+package backtest
+
+import (
+	"time"
+
+	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/internal"
+)
+
+// Underwater returns the drawdown of the equity at every moment of Equity.Timestamp:
+// the relative distance of the value below its running maximum. The values are
+// zero at new highs and negative otherwise, e.g. -0.2 for a 20% drawdown.
+func Underwater(equity data.Equity) []float64 {
+	deposit := equity.Deposit()
+	underwater := make([]float64, len(deposit))
+
+	peak := 0.0
+
+	for i, value := range deposit {
+		if i == 0 || value > peak {
+			peak = value
+		}
+
+		if peak > 0 {
+			underwater[i] = value/peak - 1
+		}
+	}
+
+	return underwater
+}
+
+// drawdown is a period during which the equity is below its previous maximum.
+type drawdown struct {
+	start     int     // Index of the maximum preceding the drawdown.
+	trough    int     // Index of the lowest value of the period.
+	end       int     // Index of the recovery, or of the last value if the equity has not recovered.
+	depth     float64 // Relative depth at the trough as a positive fraction.
+	recovered bool
+}
+
+// drawdowns splits the underwater curve of the equity into drawdown periods.
+func drawdowns(equity data.Equity) []drawdown {
+	underwater := Underwater(equity)
+	periods := make([]drawdown, 0, internal.DefaultCapacity)
+
+	var current *drawdown
+
+	for i, value := range underwater {
+		switch {
+		case value < 0 && current == nil:
+			current = &drawdown{start: i - 1, trough: i, depth: -value}
+		case value < 0 && -value > current.depth:
+			current.trough = i
+			current.depth = -value
+		case value == 0 && current != nil:
+			current.end = i
+			current.recovered = true
+			periods = internal.Append(periods, *current)
+			current = nil
+		}
+	}
+
+	if current != nil {
+		current.end = len(underwater) - 1
+		periods = internal.Append(periods, *current)
+	}
+
+	return periods
+}
+
+// elapsed returns the time between two records of the equity.
+func elapsed(equity data.Equity, from, to int) time.Duration {
+	return equity.Timestamp.At(to).Sub(equity.Timestamp.At(from))
+}
+
+// MaxDrawdown is the largest relative decline of the equity
+// from a maximum, e.g. 0.2 for a 20% drawdown.
+type MaxDrawdown struct{}
+
+func (m MaxDrawdown) Evaluate(equity data.Equity) float64 {
+	maximum := 0.0
+
+	for _, period := range drawdowns(equity) {
+		if period.depth > maximum {
+			maximum = period.depth
+		}
+	}
+
+	return maximum
+}
+
+// AverageDrawdown is the mean depth of all drawdown periods of the equity.
+type AverageDrawdown struct{}
+
+func (a AverageDrawdown) Evaluate(equity data.Equity) float64 {
+	periods := drawdowns(equity)
+	if len(periods) == 0 {
+		return 0
+	}
+
+	sum := 0.0
+	for _, period := range periods {
+		sum += period.depth
+	}
+
+	return sum / float64(len(periods))
+}
+
+// DrawdownDuration is the longest time the equity has spent below a previous
+// maximum, including the current drawdown if it has not recovered yet.
+// The result is a time.Duration converted to float64.
+type DrawdownDuration struct{}
+
+func (d DrawdownDuration) Evaluate(equity data.Equity) float64 {
+	var longest time.Duration
+
+	for _, period := range drawdowns(equity) {
+		if duration := elapsed(equity, period.start, period.end); duration > longest {
+			longest = duration
+		}
+	}
+
+	return float64(longest)
+}
+
+// RecoveryTime is the time from the trough of the maximum drawdown to the
+// moment the equity reached the previous maximum again. If the equity has not
+// recovered, it is the time from the trough to the last record.
+// The result is a time.Duration converted to float64.
+type RecoveryTime struct{}
+
+func (r RecoveryTime) Evaluate(equity data.Equity) float64 {
+	var deepest *drawdown
+
+	periods := drawdowns(equity)
+	for i := range periods {
+		if deepest == nil || periods[i].depth > deepest.depth {
+			deepest = &periods[i]
+		}
+	}
+
+	if deepest == nil {
+		return 0
+	}
+
+	return float64(elapsed(equity, deepest.trough, deepest.end))
+}
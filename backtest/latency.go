@@ -0,0 +1,123 @@
+package backtest
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/errors"
+	"github.com/quick-trade/xoney/events"
+	"github.com/quick-trade/xoney/exchange"
+)
+
+// LatencyModel defines the delay between the moment an event is produced
+// by the trading system and the moment it reaches the exchange.
+type LatencyModel interface {
+	Delay() time.Duration
+}
+
+// FixedLatency delays every event by the same duration.
+type FixedLatency time.Duration
+
+func (f FixedLatency) Delay() time.Duration { return time.Duration(f) }
+
+// RandomLatency delays events by a duration drawn uniformly from [min, max].
+// The delays are reproducible: the sequence is restarted from the seed at
+// the start of every backtest.
+type RandomLatency struct {
+	min       time.Duration
+	max       time.Duration
+	seed      int64
+	generator *rand.Rand
+}
+
+// NewRandomLatency creates a RandomLatency with the given bounds and seed.
+func NewRandomLatency(min, max time.Duration, seed int64) (*RandomLatency, error) {
+	if min < 0 {
+		return nil, errors.NewIncorrectDurationError(min)
+	}
+
+	if max < min {
+		return nil, errors.NewIncorrectDurationError(max)
+	}
+
+	latency := &RandomLatency{min: min, max: max, seed: seed}
+	latency.reset()
+
+	return latency, nil
+}
+
+func (r *RandomLatency) Delay() time.Duration {
+	return r.min + time.Duration(r.generator.Int63n(int64(r.max-r.min)+1))
+}
+
+func (r *RandomLatency) reset() {
+	r.generator = rand.New(rand.NewSource(r.seed))
+}
+
+// resettable is implemented by latency models with a state
+// that has to be restored before every backtest.
+type resettable interface {
+	reset()
+}
+
+// pendingEvent is an event on its way to the exchange.
+type pendingEvent struct {
+	arrival time.Time
+	event   events.Event
+}
+
+// eventQueue holds the pending events ordered by the moment of arrival.
+// Events arriving at the same moment keep the order in which they were produced.
+type eventQueue []pendingEvent
+
+func (q *eventQueue) push(arrival time.Time, event events.Event) {
+	index := sort.Search(len(*q), func(i int) bool {
+		return (*q)[i].arrival.After(arrival)
+	})
+
+	*q = append(*q, pendingEvent{})
+	copy((*q)[index+1:], (*q)[index:])
+	(*q)[index] = pendingEvent{arrival: arrival, event: event}
+}
+
+// pop removes and returns the events that have arrived by the moment.
+func (q *eventQueue) pop(moment time.Time) []events.Event {
+	count := sort.Search(len(*q), func(i int) bool {
+		return (*q)[i].arrival.After(moment)
+	})
+
+	arrived := make([]events.Event, count)
+	for i, pending := range (*q)[:count] {
+		arrived[i] = pending.event
+	}
+
+	*q = (*q)[count:]
+
+	return arrived
+}
+
+// delayedConnector passes the events that arrived late to the simulator.
+// Market orders created by the system carry the price it observed;
+// they are executed at the market price at the moment of arrival instead.
+type delayedConnector struct {
+	exchange.Simulator
+}
+
+func (d delayedConnector) PlaceOrder(order exchange.Order) error {
+	if order.Type() != exchange.Market {
+		return d.Simulator.PlaceOrder(order)
+	}
+
+	prices, errs := d.GetPrices([]data.Symbol{order.Symbol()})
+	for price := range prices {
+		order.SetPrice(price.Price)
+	}
+
+	if err := <-errs; err != nil {
+		return err
+	}
+
+	return d.Simulator.PlaceOrder(order)
+}
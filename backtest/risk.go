@@ -0,0 +1,223 @@
+package backtest
+
+import (
+	"math"
+	"sort"
+
+	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/internal"
+)
+
+// The metrics of this file are calculated from the simple returns of the equity.
+// Returns and thresholds are annualized by Equity.Timeframe().CandlesPerYear as in
+// SharpeRatio; deviations are annualized by its square root.
+
+// Sortino is the annualized excess return over the target divided by the
+// annualized downside deviation: the deviation of returns below the target.
+type Sortino struct {
+	Target float64 // Annual target return, e.g. 0.05.
+}
+
+func (s Sortino) Evaluate(equity data.Equity) float64 {
+	returns := internal.Returns(equity.Deposit())
+	mean, err := internal.RawMoment(returns, 1)
+	if err != nil {
+		return 0
+	}
+
+	inYear := equity.Timeframe().CandlesPerYear
+	target := s.Target / inYear
+
+	downside := 0.0
+	for _, value := range returns {
+		if value < target {
+			downside += (value - target) * (value - target)
+		}
+	}
+
+	deviation := math.Sqrt(downside / float64(len(returns)))
+
+	return (mean*inYear - s.Target) / (deviation * math.Sqrt(inYear))
+}
+
+// Calmar is the annualized return divided by the maximum drawdown.
+type Calmar struct{}
+
+func (c Calmar) Evaluate(equity data.Equity) float64 {
+	mean, err := internal.RawMoment(internal.Returns(equity.Deposit()), 1)
+	if err != nil {
+		return 0
+	}
+
+	return mean * equity.Timeframe().CandlesPerYear / MaxDrawdown{}.Evaluate(equity)
+}
+
+// Omega is the ratio of the sum of returns above the threshold
+// to the sum of shortfalls below it.
+type Omega struct {
+	Threshold float64 // Annual threshold return, e.g. 0 to compare gains and losses.
+}
+
+func (o Omega) Evaluate(equity data.Equity) float64 {
+	returns := internal.Returns(equity.Deposit())
+	if len(returns) == 0 {
+		return 0
+	}
+
+	threshold := o.Threshold / equity.Timeframe().CandlesPerYear
+
+	gains, losses := 0.0, 0.0
+
+	for _, value := range returns {
+		if value > threshold {
+			gains += value - threshold
+		} else {
+			losses += threshold - value
+		}
+	}
+
+	return gains / losses
+}
+
+// VaRMethod defines how the distribution of returns is estimated
+// by the value at risk metrics.
+type VaRMethod string
+
+const (
+	// HistoricalVaR uses the empirical distribution of returns.
+	HistoricalVaR VaRMethod = "historical"
+	// ParametricVaR assumes normally distributed returns.
+	ParametricVaR VaRMethod = "parametric"
+	// CornishFisherVaR adjusts the normal quantiles for the skewness and
+	// the excess kurtosis of returns.
+	CornishFisherVaR VaRMethod = "cornish_fisher"
+)
+
+// cvarSteps is the number of quantiles averaged by the Cornish-Fisher CVaR.
+const cvarSteps = 1000
+
+// VaR is the value at risk: the loss of one candle, as a positive fraction of the
+// equity, that is not exceeded with the given confidence, e.g. 0.95. The historical
+// VaR is the smallest loss among the worst (1 - confidence) share of returns.
+type VaR struct {
+	Confidence float64
+	Method     VaRMethod
+}
+
+func (v VaR) Evaluate(equity data.Equity) float64 {
+	returns := internal.Returns(equity.Deposit())
+	if len(returns) == 0 {
+		return 0
+	}
+
+	if v.Method == HistoricalVaR {
+		sorted := sortedReturns(returns)
+
+		return -sorted[tailLength(len(sorted), v.Confidence)-1]
+	}
+
+	return -newDistribution(returns, v.Method).quantile(1 - v.Confidence)
+}
+
+// CVaR is the conditional value at risk (expected shortfall): the average loss
+// of one candle, as a positive fraction of the equity, in the cases when it
+// exceeds the value at risk with the given confidence.
+type CVaR struct {
+	Confidence float64
+	Method     VaRMethod
+}
+
+func (c CVaR) Evaluate(equity data.Equity) float64 {
+	returns := internal.Returns(equity.Deposit())
+	if len(returns) == 0 {
+		return 0
+	}
+
+	tail := 1 - c.Confidence
+
+	switch c.Method {
+	case HistoricalVaR:
+		sorted := sortedReturns(returns)
+		worst := sorted[:tailLength(len(sorted), c.Confidence)]
+		mean, _ := internal.RawMoment(worst, 1)
+
+		return -mean
+	case ParametricVaR:
+		dist := newDistribution(returns, c.Method)
+		z := normalQuantile(tail)
+
+		return -(dist.mean - dist.std*normalDensity(z)/tail)
+	default:
+		// The expected shortfall is the average of the quantiles within the tail.
+		dist := newDistribution(returns, c.Method)
+		sum := 0.0
+
+		for i := 0; i < cvarSteps; i++ {
+			sum += dist.quantile(tail * (float64(i) + 0.5) / cvarSteps)
+		}
+
+		return -sum / cvarSteps
+	}
+}
+
+func sortedReturns(returns []float64) []float64 {
+	sorted := append([]float64(nil), returns...)
+	sort.Float64s(sorted)
+
+	return sorted
+}
+
+// tailEpsilon protects the number of returns in the tail from rounding errors.
+const tailEpsilon = 1e-9
+
+// tailLength returns the number of the worst returns that form the tail
+// beyond the confidence level. The tail contains at least one return.
+func tailLength(length int, confidence float64) int {
+	count := int(math.Ceil((1-confidence)*float64(length) - tailEpsilon))
+
+	return max(1, min(length, count))
+}
+
+// distribution describes returns by their moments.
+type distribution struct {
+	mean     float64
+	std      float64
+	skewness float64
+	kurtosis float64 // Excess kurtosis.
+	adjusted bool    // Whether the Cornish-Fisher expansion is applied.
+}
+
+func newDistribution(returns []float64, method VaRMethod) distribution {
+	mean, _ := internal.RawMoment(returns, 1)
+	variance := internal.CentralMoment(returns, mean, 2)
+	std := math.Sqrt(variance)
+
+	dist := distribution{mean: mean, std: std, adjusted: method == CornishFisherVaR}
+
+	if variance > 0 {
+		dist.skewness = internal.CentralMoment(returns, mean, 3) / math.Pow(std, 3)
+		dist.kurtosis = internal.CentralMoment(returns, mean, 4)/(variance*variance) - 3
+	}
+
+	return dist
+}
+
+// quantile returns the return that is not exceeded with the probability.
+func (d distribution) quantile(probability float64) float64 {
+	z := normalQuantile(probability)
+
+	if d.adjusted {
+		s, k := d.skewness, d.kurtosis
+		z += (z*z-1)*s/6 + (z*z*z-3*z)*k/24 - (2*z*z*z-5*z)*s*s/36
+	}
+
+	return d.mean + z*d.std
+}
+
+func normalQuantile(probability float64) float64 {
+	return math.Sqrt2 * math.Erfinv(2*probability-1)
+}
+
+func normalDensity(z float64) float64 {
+	return math.Exp(-z*z/2) / math.Sqrt(2*math.Pi)
+}
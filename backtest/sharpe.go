@@ -0,0 +1,130 @@
+package backtest
+
+import (
+	"math"
+	"time"
+
+	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/errors"
+	"github.com/quick-trade/xoney/internal"
+)
+
+// The metrics of this file follow Bailey and López de Prado. Sharpe ratios are
+// annual, they are converted to a single candle by the square root of
+// Equity.Timeframe().CandlesPerYear.
+
+// eulerMascheroni is the Euler–Mascheroni constant.
+const eulerMascheroni = 0.5772156649015329
+
+// sharpeEstimate describes the Sharpe ratio of one candle estimated from the returns.
+type sharpeEstimate struct {
+	distribution
+	sharpe       float64
+	observations int
+}
+
+func newSharpeEstimate(equity data.Equity) (sharpeEstimate, error) {
+	returns := internal.Returns(equity.Deposit())
+	if len(returns) == 0 {
+		return sharpeEstimate{}, errors.NewZeroLengthError("returns")
+	}
+
+	dist := newDistribution(returns, ParametricVaR)
+
+	return sharpeEstimate{
+		distribution: dist,
+		sharpe:       dist.mean / dist.std,
+		observations: len(returns),
+	}, nil
+}
+
+// dispersion returns the variance of the estimation error of the Sharpe
+// ratio multiplied by the number of observations.
+func (s sharpeEstimate) dispersion() float64 {
+	return 1 - s.skewness*s.sharpe + (s.kurtosis+2)/4*s.sharpe*s.sharpe
+}
+
+// probability returns the probability that the true Sharpe ratio of one candle exceeds the benchmark.
+func (s sharpeEstimate) probability(benchmark float64) float64 {
+	if s.observations < 2 || math.IsNaN(s.sharpe) {
+		return 0
+	}
+
+	z := (s.sharpe - benchmark) * math.Sqrt(float64(s.observations-1)) / math.Sqrt(s.dispersion())
+
+	return normalProbability(z)
+}
+
+// ProbabilisticSharpe is the probability that the true Sharpe ratio exceeds the
+// benchmark given the length of the equity, the skewness and the kurtosis of returns.
+type ProbabilisticSharpe struct {
+	Benchmark float64 // Annual Sharpe ratio, e.g. 0 to test that the strategy is profitable.
+}
+
+func (p ProbabilisticSharpe) Evaluate(equity data.Equity) float64 {
+	estimate, err := newSharpeEstimate(equity)
+	if err != nil {
+		return 0
+	}
+
+	return estimate.probability(p.Benchmark / math.Sqrt(equity.Timeframe().CandlesPerYear))
+}
+
+// DeflatedSharpe is the probabilistic Sharpe ratio with the benchmark equal to
+// the expected maximum Sharpe ratio of the trials with zero true Sharpe ratio.
+// It accounts for the selection of the best result among many tested ones,
+// e.g. parameter sets of an optimization.
+type DeflatedSharpe struct {
+	Trials   int     // Number of independent tested configurations.
+	Variance float64 // Variance of the annual Sharpe ratios of the trials.
+}
+
+func (d DeflatedSharpe) Evaluate(equity data.Equity) float64 {
+	estimate, err := newSharpeEstimate(equity)
+	if err != nil {
+		return 0
+	}
+
+	return estimate.probability(d.expectedMaximum(equity.Timeframe().CandlesPerYear))
+}
+
+// expectedMaximum returns the expected maximum Sharpe ratio of one candle among the trials.
+func (d DeflatedSharpe) expectedMaximum(inYear float64) float64 {
+	if d.Trials < 2 {
+		return 0
+	}
+
+	trials := float64(d.Trials)
+	maximum := (1-eulerMascheroni)*normalQuantile(1-1/trials) +
+		eulerMascheroni*normalQuantile(1-1/(trials*math.E))
+
+	return math.Sqrt(d.Variance/inYear) * maximum
+}
+
+// MinTrackRecordLength returns the length of the equity required to conclude
+// with the confidence, e.g. 0.95, that the true Sharpe ratio exceeds the annual
+// benchmark, assuming the observed moments of returns. The error is returned when
+// the observed Sharpe ratio does not exceed the benchmark.
+func MinTrackRecordLength(equity data.Equity, benchmark, confidence float64) (time.Duration, error) {
+	estimate, err := newSharpeEstimate(equity)
+	if err != nil {
+		return 0, err
+	}
+
+	timeframe := equity.Timeframe()
+	scale := math.Sqrt(timeframe.CandlesPerYear)
+	target := benchmark / scale
+
+	if !(estimate.sharpe > target) {
+		return 0, errors.NewInsufficientSharpeError(estimate.sharpe*scale, benchmark)
+	}
+
+	z := normalQuantile(confidence) / (estimate.sharpe - target)
+	candles := 1 + estimate.dispersion()*z*z
+
+	return time.Duration(math.Ceil(candles)) * timeframe.Duration, nil
+}
+
+func normalProbability(z float64) float64 {
+	return (1 + math.Erf(z/math.Sqrt2)) / 2
+}
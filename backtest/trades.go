@@ -0,0 +1,282 @@
+package backtest
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/exchange"
+	"github.com/quick-trade/xoney/internal"
+)
+
+// flatEpsilon is the share of the entered amount below which a position is considered closed.
+const flatEpsilon = 1e-9
+
+// RoundTrip is a trade reconstructed from fills: a position in a symbol from
+// its opening to the moment it is closed. A fill that reverses the position
+// closes the round trip and opens the next one.
+type RoundTrip struct {
+	Symbol     data.Symbol
+	Side       exchange.OrderSide // Buy for long trades, Sell for short ones.
+	Entry      time.Time
+	Exit       time.Time
+	EntryPrice float64 // Average price of the entries.
+	ExitPrice  float64 // Average price of the exits.
+	Amount     float64 // Total entered amount in the base currency.
+	Fees       float64 // Fees in the quote currency.
+	PnL        float64 // Realized profit in the quote currency net of fees.
+}
+
+// HoldingTime returns the time the position was open.
+func (r RoundTrip) HoldingTime() time.Duration { return r.Exit.Sub(r.Entry) }
+
+// Return returns the profit relative to the value of the entries.
+func (r RoundTrip) Return() float64 { return r.PnL / (r.EntryPrice * r.Amount) }
+
+// tripBuilder accumulates the fills of a round trip that is still open.
+type tripBuilder struct {
+	trip       RoundTrip
+	position   float64 // Signed amount of the open position.
+	entryValue float64
+	exitValue  float64
+	exitAmount float64
+}
+
+func (t *tripBuilder) enter(fill exchange.Fill, amount, fee float64) {
+	t.trip.Amount += amount
+	t.trip.Fees += fee
+	t.entryValue += amount * fill.Price
+	t.position += signedAmount(fill.Side, amount)
+}
+
+func (t *tripBuilder) exit(fill exchange.Fill, amount, fee float64) {
+	t.trip.Fees += fee
+	t.exitValue += amount * fill.Price
+	t.exitAmount += amount
+	t.position += signedAmount(fill.Side, amount)
+}
+
+func (t *tripBuilder) closed() bool {
+	return math.Abs(t.position) <= flatEpsilon*t.trip.Amount
+}
+
+// build completes the round trip closed at the moment.
+func (t *tripBuilder) build(moment time.Time) RoundTrip {
+	trip := t.trip
+	trip.Exit = moment
+	trip.EntryPrice = t.entryValue / trip.Amount
+	trip.ExitPrice = t.exitValue / t.exitAmount
+
+	profit := t.exitValue - t.entryValue
+	if trip.Side == exchange.Sell {
+		profit = -profit
+	}
+
+	trip.PnL = profit - trip.Fees
+
+	return trip
+}
+
+func signedAmount(side exchange.OrderSide, amount float64) float64 {
+	if side == exchange.Sell {
+		return -amount
+	}
+
+	return amount
+}
+
+// feeInQuote returns the fee of the fill in the quote currency. Fees charged
+// in other currencies than the base and the quote ones are not included.
+func feeInQuote(fill exchange.Fill) float64 {
+	switch fill.FeeCurrency {
+	case fill.Symbol.Quote():
+		return fill.Fee
+	case fill.Symbol.Base():
+		return fill.Fee * fill.Price
+	default:
+		return 0
+	}
+}
+
+// RoundTrips reconstructs the closed round trips from the fills,
+// e.g. the journal of a backtest. The trips are ordered by exit time.
+func RoundTrips(fills []exchange.Fill) []RoundTrip {
+	trips, _ := reconstruct(fills)
+
+	return trips
+}
+
+// reconstruct returns the closed round trips and the ones still open after the last fill.
+func reconstruct(fills []exchange.Fill) ([]RoundTrip, []RoundTrip) {
+	closed := make([]RoundTrip, 0, internal.DefaultCapacity)
+	builders := make(map[data.Symbol]*tripBuilder)
+
+	for _, fill := range fills {
+		if fill.Amount <= 0 {
+			continue
+		}
+
+		remaining := fill.Amount
+		fee := feeInQuote(fill)
+
+		if builder, ok := builders[fill.Symbol]; ok && builder.trip.Side != fill.Side {
+			amount := math.Min(remaining, math.Abs(builder.position))
+			builder.exit(fill, amount, fee*amount/fill.Amount)
+			remaining -= amount
+
+			if builder.closed() {
+				closed = internal.Append(closed, builder.build(fill.Time))
+				delete(builders, fill.Symbol)
+			}
+		}
+
+		if remaining <= flatEpsilon*fill.Amount {
+			continue
+		}
+
+		builder, ok := builders[fill.Symbol]
+		if !ok {
+			builder = &tripBuilder{trip: RoundTrip{Symbol: fill.Symbol, Side: fill.Side, Entry: fill.Time}}
+			builders[fill.Symbol] = builder
+		}
+
+		builder.enter(fill, remaining, fee*remaining/fill.Amount)
+	}
+
+	open := make([]RoundTrip, 0, len(builders))
+	for _, builder := range builders {
+		open = internal.Append(open, builder.trip)
+	}
+
+	sort.SliceStable(closed, func(i, j int) bool {
+		return closed[i].Exit.Before(closed[j].Exit)
+	})
+
+	return closed, open
+}
+
+// TradeReport summarizes the round trips of a backtest. Profits of trades in
+// different symbols are added up, so they are expected to share the quote currency.
+type TradeReport struct {
+	Trades               int           // Number of closed round trips.
+	WinRate              float64       // Share of trades with positive profit.
+	AverageWin           float64       // Mean profit of winning trades.
+	AverageLoss          float64       // Mean loss of losing trades as a positive value.
+	ProfitFactor         float64       // Gross profit divided by gross loss.
+	Expectancy           float64       // Mean profit per trade.
+	MaxConsecutiveLosses int           // Longest series of losing trades.
+	AverageHoldingTime   time.Duration // Mean time a position was open.
+	Exposure             float64       // Share of the backtest time with at least one open position.
+}
+
+// NewTradeReport builds the report from the fills of a backtest and its equity,
+// which defines the duration of the backtest. Positions left open at the end
+// count towards the exposure only.
+func NewTradeReport(fills []exchange.Fill, equity data.Equity) TradeReport {
+	trips, open := reconstruct(fills)
+	report := TradeReport{Trades: len(trips)}
+
+	if equity.Timestamp.Len() != 0 {
+		report.Exposure = exposure(trips, open, equity.Start(), equity.Timestamp.End())
+	}
+
+	if len(trips) == 0 {
+		return report
+	}
+
+	var (
+		wins, losses           int
+		grossProfit, grossLoss float64
+		holding                time.Duration
+		consecutive            int
+	)
+
+	for _, trip := range trips {
+		holding += trip.HoldingTime()
+
+		if trip.PnL > 0 {
+			wins++
+			grossProfit += trip.PnL
+			consecutive = 0
+
+			continue
+		}
+
+		if trip.PnL < 0 {
+			losses++
+			grossLoss -= trip.PnL
+			consecutive++
+			report.MaxConsecutiveLosses = max(report.MaxConsecutiveLosses, consecutive)
+		}
+	}
+
+	report.WinRate = float64(wins) / float64(len(trips))
+	report.Expectancy = (grossProfit - grossLoss) / float64(len(trips))
+	report.ProfitFactor = grossProfit / grossLoss
+	report.AverageHoldingTime = holding / time.Duration(len(trips))
+
+	if wins != 0 {
+		report.AverageWin = grossProfit / float64(wins)
+	}
+
+	if losses != 0 {
+		report.AverageLoss = grossLoss / float64(losses)
+	}
+
+	return report
+}
+
+// exposure returns the share of the period covered by the holding
+// periods of the trips. Open trips are held until the end of the period.
+func exposure(closed, open []RoundTrip, start, end time.Time) float64 {
+	total := end.Sub(start)
+	if total <= 0 {
+		return 0
+	}
+
+	intervals := make([]data.Period, 0, len(closed)+len(open))
+	for _, trip := range closed {
+		intervals = internal.Append(intervals, data.NewPeriod(trip.Entry, trip.Exit))
+	}
+
+	for _, trip := range open {
+		intervals = internal.Append(intervals, data.NewPeriod(trip.Entry, end))
+	}
+
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i].Start.Before(intervals[j].Start)
+	})
+
+	var covered time.Duration
+
+	current := start
+
+	for _, interval := range intervals {
+		from := maxTime(interval.Start, current)
+		to := minTime(interval.End, end)
+
+		if to.After(from) {
+			covered += to.Sub(from)
+			current = to
+		}
+	}
+
+	return float64(covered) / float64(total)
+}
+
+func maxTime(first, second time.Time) time.Time {
+	if first.After(second) {
+		return first
+	}
+
+	return second
+}
+
+func minTime(first, second time.Time) time.Time {
+	if first.Before(second) {
+		return first
+	}
+
+	return second
+}
@@ -50,6 +50,13 @@ func (p Portfolio) Total(prices map[data.Currency]float64) (float64, error) {
 	return total, err
 }
 
+// TotalByGraph calculates the total value of the portfolio in the main currency
+// using the prices derived by the graph, so that currencies traded only against
+// other currencies are valued through intermediate pairs.
+func (p Portfolio) TotalByGraph(graph *PriceGraph) (float64, error) {
+	return p.Total(graph.Prices(p.mainCurrency))
+}
+
 // Balance returns the amount of the specified currency held in the portfolio.
 func (p Portfolio) Balance(currency data.Currency) float64 {
 	return p.assets[currency]
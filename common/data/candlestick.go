@@ -90,6 +90,13 @@ func (t TimeStamp) Start() time.Time {
 // Len returns the number of time moments within the TimeStamp.
 func (t TimeStamp) Len() int { return len(t.Timestamp) }
 
+// IndexBeforeOrAt returns the index of the last time moment that is not after
+// the given one. It is used to align series with different time moments.
+// An error is returned if the TimeStamp is empty or starts after the moment.
+func (t TimeStamp) IndexBeforeOrAt(moment time.Time) (int, error) {
+	return findIndexBeforeOrAtTime(t, moment)
+}
+
 // Candle represents a single candlestick data point in a financial chart,
 // encapsulating the open, high, low, close values and the volume of trading
 // over a particular time period, with TimeClose marking the end of that period.
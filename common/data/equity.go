@@ -13,6 +13,7 @@ import (
 // for each recorded value.
 type Equity struct {
 	portfolioHistory []map[Currency]float64 // history of portfolio values by currency
+	costsHistory     []map[string]float64   // history of accumulated trading costs by kind
 	mainHistory      []float64              // history of main value changes
 	Timestamp        TimeStamp              // timestamps corresponding to mainHistory records
 	timeframe        TimeFrame              // timeframe for the historical data
@@ -56,6 +57,37 @@ func (e *Equity) AddPortfolio(portfolio map[Currency]float64) {
 	e.portfolioHistory = internal.Append(e.portfolioHistory, element)
 }
 
+// CostsHistory returns the history of accumulated trading costs in the main
+// currency by their kind, e.g. commission or interest. It is empty if the
+// simulator does not report its costs.
+func (e *Equity) CostsHistory() map[string][]float64 {
+	result := make(map[string][]float64)
+
+	if len(e.costsHistory) == 0 {
+		return result
+	}
+
+	last := e.costsHistory[len(e.costsHistory)-1]
+
+	for kind := range last {
+		series := make([]float64, len(e.costsHistory))
+
+		for i, costs := range e.costsHistory {
+			series[i] = costs[kind]
+		}
+
+		result[kind] = series
+	}
+
+	return result
+}
+
+// AddCosts appends a new set of accumulated trading costs to the history.
+func (e *Equity) AddCosts(costs map[string]float64) {
+	element := internal.MapCopy(costs)
+	e.costsHistory = internal.Append(e.costsHistory, element)
+}
+
 // AddValue appends a new value to the main history and associates it with a timestamp.
 func (e *Equity) AddValue(value float64, timestamp time.Time) {
 	e.mainHistory = internal.Append(e.mainHistory, value)
@@ -81,6 +113,7 @@ func NewEquity(
 
 	return &Equity{
 		portfolioHistory: make([]map[Currency]float64, 0, internal.DefaultCapacity),
+		costsHistory:     make([]map[string]float64, 0, internal.DefaultCapacity),
 		mainHistory:      history,
 		Timestamp:        timestamp,
 		timeframe:        timeframe,
@@ -0,0 +1,103 @@
+package common
+
+import (
+	"math/rand"
+	"sync"
+
+	"github.com/quick-trade/xoney/internal"
+)
+
+// IDGenerator generates the IDs of orders and grid levels.
+type IDGenerator interface {
+	Next() uint64
+	Reset() // Restarts the sequence, so that the same IDs are generated again.
+}
+
+// SequentialIDs generates consecutive IDs starting from the given one.
+// Zero is skipped, since it stands for no group or parent order.
+type SequentialIDs struct {
+	start uint64
+	next  uint64
+}
+
+func NewSequentialIDs(start uint64) *SequentialIDs {
+	return &SequentialIDs{start: start, next: start}
+}
+
+func (s *SequentialIDs) Next() uint64 {
+	if s.next == 0 {
+		s.next++
+	}
+
+	id := s.next
+	s.next++
+
+	return id
+}
+
+func (s *SequentialIDs) Reset() { s.next = s.start }
+
+// SeededIDs generates pseudo-random IDs reproducible by the seed.
+type SeededIDs struct {
+	seed      int64
+	generator *rand.Rand
+}
+
+func NewSeededIDs(seed int64) *SeededIDs {
+	return &SeededIDs{seed: seed, generator: rand.New(rand.NewSource(seed))}
+}
+
+func (s *SeededIDs) Next() uint64 { return s.generator.Uint64() }
+
+func (s *SeededIDs) Reset() { s.generator = rand.New(rand.NewSource(s.seed)) }
+
+// randomIDs generates IDs from the global random source. It is used by default.
+type randomIDs struct{}
+
+func (randomIDs) Next() uint64 { return internal.RandomUint64() }
+func (randomIDs) Reset()       {}
+
+var (
+	idMutex     sync.Mutex
+	idGenerator IDGenerator = randomIDs{}
+)
+
+// SetIDGenerator replaces the generator of the IDs of orders and grid levels
+// and returns the previous one. Nil restores the default generator of random IDs.
+func SetIDGenerator(generator IDGenerator) IDGenerator {
+	idMutex.Lock()
+	defer idMutex.Unlock()
+
+	if generator == nil {
+		generator = randomIDs{}
+	}
+
+	previous := idGenerator
+	idGenerator = generator
+
+	return previous
+}
+
+// UseIDGenerator installs the generator while the function runs and restores
+// the previous one afterwards. The generator is process-wide: IDs created
+// concurrently by other goroutines are drawn from it as well.
+func UseIDGenerator(generator IDGenerator, run func() error) error {
+	previous := SetIDGenerator(generator)
+	defer SetIDGenerator(previous)
+
+	return run()
+}
+
+// NextID returns the next nonzero ID of the current generator.
+// It is safe for concurrent use.
+func NextID() uint64 {
+	idMutex.Lock()
+	defer idMutex.Unlock()
+
+	id := idGenerator.Next()
+	for id == 0 {
+		id = idGenerator.Next()
+	}
+
+	return id
+}
@@ -0,0 +1,119 @@
+package common
+
+import (
+	"sort"
+
+	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/errors"
+	"github.com/quick-trade/xoney/internal"
+)
+
+// PriceGraph holds the last prices of traded pairs and derives prices of
+// currencies that are not traded directly against each other through
+// intermediate pairs, e.g. ETH in USD through ETH/BTC and BTC/USD.
+// The shortest chain of pairs is used.
+type PriceGraph struct {
+	// rates maps a currency to the quantity of every directly
+	// traded currency that is paid for one unit of it.
+	rates map[data.Currency]map[data.Currency]float64
+}
+
+// NewPriceGraph creates an empty PriceGraph.
+func NewPriceGraph() *PriceGraph {
+	return &PriceGraph{
+		rates: make(map[data.Currency]map[data.Currency]float64, internal.DefaultCapacity),
+	}
+}
+
+// Update sets the last price of the symbol. Non-positive prices are ignored.
+func (g *PriceGraph) Update(symbol data.Symbol, price float64) {
+	if price <= 0 {
+		return
+	}
+
+	g.setRate(symbol.Base(), symbol.Quote(), price)
+	g.setRate(symbol.Quote(), symbol.Base(), 1/price)
+}
+
+func (g *PriceGraph) setRate(from, to data.Currency, rate float64) {
+	if _, ok := g.rates[from]; !ok {
+		g.rates[from] = make(map[data.Currency]float64)
+	}
+
+	g.rates[from][to] = rate
+}
+
+// Rate returns the price of the base currency in the quote currency
+// if the pair is traded directly.
+func (g *PriceGraph) Rate(base, quote data.Currency) (float64, bool) {
+	rate, ok := g.rates[base][quote]
+
+	return rate, ok
+}
+
+// Price returns the price of the currency in the target currency,
+// derived through intermediate pairs if needed.
+func (g *PriceGraph) Price(currency, target data.Currency) (float64, error) {
+	if currency == target {
+		return 1, nil
+	}
+
+	price, ok := g.search(target, currency)[currency]
+	if !ok {
+		return 0, errors.NewNoPriceError(currency.String())
+	}
+
+	return price, nil
+}
+
+// Prices returns the prices in the target currency of all currencies
+// connected to it through traded pairs. The target itself is not included.
+func (g *PriceGraph) Prices(target data.Currency) map[data.Currency]float64 {
+	prices := g.search(target, data.Currency{})
+	delete(prices, target)
+
+	return prices
+}
+
+// search walks the graph from the target breadth-first and values every
+// reached currency in the target. It stops once the wanted currency is valued.
+func (g *PriceGraph) search(target, wanted data.Currency) map[data.Currency]float64 {
+	prices := map[data.Currency]float64{target: 1}
+	queue := []data.Currency{target}
+
+	for len(queue) != 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, neighbour := range g.neighbours(current) {
+			if _, visited := prices[neighbour]; visited {
+				continue
+			}
+
+			prices[neighbour] = g.rates[neighbour][current] * prices[current]
+
+			if neighbour == wanted {
+				return prices
+			}
+
+			queue = append(queue, neighbour)
+		}
+	}
+
+	return prices
+}
+
+// neighbours returns the currencies traded directly against the currency
+// in a stable order, so that derived prices do not depend on map iteration.
+func (g *PriceGraph) neighbours(currency data.Currency) []data.Currency {
+	neighbours := make([]data.Currency, 0, len(g.rates[currency]))
+	for neighbour := range g.rates[currency] {
+		neighbours = append(neighbours, neighbour)
+	}
+
+	sort.Slice(neighbours, func(i, j int) bool {
+		return neighbours[i].String() < neighbours[j].String()
+	})
+
+	return neighbours
+}
@@ -106,6 +106,190 @@ func NewNoLimitOrderError(id uint64) NoLimitOrderError {
 	return NoLimitOrderError{id: id}
 }
 
+type UnknownOrderError struct {
+	id uint64
+}
+
+func (e UnknownOrderError) Error() string {
+	var msg strings.Builder
+
+	msg.WriteString("unknown order ID: ")
+	msg.WriteString(strconv.FormatUint(e.id, 10))
+	msg.WriteRune('.')
+
+	return msg.String()
+}
+
+func NewUnknownOrderError(id uint64) UnknownOrderError {
+	return UnknownOrderError{id: id}
+}
+
+type OrderRejectedError struct {
+	OrderID uint64
+	Reason  error
+}
+
+func (e OrderRejectedError) Error() string {
+	var msg strings.Builder
+
+	msg.WriteString("order ")
+	msg.WriteString(strconv.FormatUint(e.OrderID, 10))
+	msg.WriteString(" rejected: ")
+	msg.WriteString(e.Reason.Error())
+
+	return msg.String()
+}
+
+func (e OrderRejectedError) Unwrap() error { return e.Reason }
+
+func NewOrderRejectedError(orderID uint64, reason error) OrderRejectedError {
+	return OrderRejectedError{OrderID: orderID, Reason: reason}
+}
+
+type InsufficientMarginError struct {
+	Required  float64
+	Available float64
+}
+
+func (e InsufficientMarginError) Error() string {
+	var msg strings.Builder
+
+	msg.WriteString("insufficient margin: required ")
+	msg.WriteString(strconv.FormatFloat(e.Required, 'f', -1, 64))
+	msg.WriteString(", available ")
+	msg.WriteString(strconv.FormatFloat(e.Available, 'f', -1, 64))
+	msg.WriteRune('.')
+
+	return msg.String()
+}
+
+func NewInsufficientMarginError(required, available float64) InsufficientMarginError {
+	return InsufficientMarginError{Required: required, Available: available}
+}
+
+type PriceTickError struct {
+	Price    float64
+	TickSize float64
+}
+
+func (e PriceTickError) Error() string {
+	var msg strings.Builder
+
+	msg.WriteString("price ")
+	msg.WriteString(strconv.FormatFloat(e.Price, 'f', -1, 64))
+	msg.WriteString(" is not a multiple of tick size ")
+	msg.WriteString(strconv.FormatFloat(e.TickSize, 'f', -1, 64))
+	msg.WriteRune('.')
+
+	return msg.String()
+}
+
+func NewPriceTickError(price, tickSize float64) PriceTickError {
+	return PriceTickError{Price: price, TickSize: tickSize}
+}
+
+type LotSizeError struct {
+	Amount  float64
+	LotSize float64
+}
+
+func (e LotSizeError) Error() string {
+	var msg strings.Builder
+
+	msg.WriteString("amount ")
+	msg.WriteString(strconv.FormatFloat(e.Amount, 'f', -1, 64))
+	msg.WriteString(" is not a multiple of lot size ")
+	msg.WriteString(strconv.FormatFloat(e.LotSize, 'f', -1, 64))
+	msg.WriteRune('.')
+
+	return msg.String()
+}
+
+func NewLotSizeError(amount, lotSize float64) LotSizeError {
+	return LotSizeError{Amount: amount, LotSize: lotSize}
+}
+
+type MinNotionalError struct {
+	Notional    float64
+	MinNotional float64
+}
+
+func (e MinNotionalError) Error() string {
+	var msg strings.Builder
+
+	msg.WriteString("order notional ")
+	msg.WriteString(strconv.FormatFloat(e.Notional, 'f', -1, 64))
+	msg.WriteString(" is below the minimum ")
+	msg.WriteString(strconv.FormatFloat(e.MinNotional, 'f', -1, 64))
+	msg.WriteRune('.')
+
+	return msg.String()
+}
+
+func NewMinNotionalError(notional, minNotional float64) MinNotionalError {
+	return MinNotionalError{Notional: notional, MinNotional: minNotional}
+}
+
+type UnknownExchangeError struct {
+	Exchange string
+}
+
+func (e UnknownExchangeError) Error() string {
+	var msg strings.Builder
+
+	msg.WriteString("unknown exchange: ")
+	msg.WriteString(e.Exchange)
+	msg.WriteRune('.')
+
+	return msg.String()
+}
+
+func NewUnknownExchangeError(exchange string) UnknownExchangeError {
+	return UnknownExchangeError{Exchange: exchange}
+}
+
+type TransferFeeError struct {
+	Quantity float64
+	Fee      float64
+}
+
+func (e TransferFeeError) Error() string {
+	var msg strings.Builder
+
+	msg.WriteString("transferred quantity ")
+	msg.WriteString(strconv.FormatFloat(e.Quantity, 'f', -1, 64))
+	msg.WriteString(" does not cover the withdrawal fee ")
+	msg.WriteString(strconv.FormatFloat(e.Fee, 'f', -1, 64))
+	msg.WriteRune('.')
+
+	return msg.String()
+}
+
+func NewTransferFeeError(quantity, fee float64) TransferFeeError {
+	return TransferFeeError{Quantity: quantity, Fee: fee}
+}
+
+type InsufficientSharpeError struct {
+	Sharpe    float64
+	Benchmark float64
+}
+
+func (e InsufficientSharpeError) Error() string {
+	var msg strings.Builder
+
+	msg.WriteString("sharpe ratio ")
+	msg.WriteString(strconv.FormatFloat(e.Sharpe, 'f', -1, 64))
+	msg.WriteString(" does not exceed the benchmark ")
+	msg.WriteString(strconv.FormatFloat(e.Benchmark, 'f', -1, 64))
+	msg.WriteRune('.')
+
+	return msg.String()
+}
+
+func NewInsufficientSharpeError(sharpe, benchmark float64) InsufficientSharpeError {
+	return InsufficientSharpeError{Sharpe: sharpe, Benchmark: benchmark}
+}
+
 type InvalidOrderAmountError struct {
 	Amount float64
 }
@@ -123,6 +307,44 @@ func NewInvalidOrderAmountError(amount float64) InvalidOrderAmountError {
 	return InvalidOrderAmountError{Amount: amount}
 }
 
+type OrderConstructorError struct {
+	OrderType   string
+	Constructor string
+}
+
+func (e OrderConstructorError) Error() string {
+	var msg strings.Builder
+
+	msg.WriteString("orders of type ")
+	msg.WriteString(e.OrderType)
+	msg.WriteString(" must be created with ")
+	msg.WriteString(e.Constructor)
+	msg.WriteRune('.')
+
+	return msg.String()
+}
+
+func NewOrderConstructorError(orderType, constructor string) OrderConstructorError {
+	return OrderConstructorError{OrderType: orderType, Constructor: constructor}
+}
+
+type InvalidTrailingOffsetError struct {
+	Offset float64
+}
+
+func (e InvalidTrailingOffsetError) Error() string {
+	var msg strings.Builder
+
+	msg.WriteString("invalid trailing stop offset: ")
+	msg.WriteString(strconv.FormatFloat(e.Offset, 'f', -1, 64))
+
+	return msg.String()
+}
+
+func NewInvalidTrailingOffsetError(offset float64) InvalidTrailingOffsetError {
+	return InvalidTrailingOffsetError{Offset: offset}
+}
+
 type InvalidSymbolError struct {
 	Base  string
 	Quote string
@@ -141,6 +363,27 @@ func NewInvalidSymbolError(base, quote string) InvalidSymbolError {
 	return InvalidSymbolError{Base: base, Quote: quote}
 }
 
+type EmptyBookError struct {
+	Symbol string
+	Side   string
+}
+
+func (e EmptyBookError) Error() string {
+	var msg strings.Builder
+
+	msg.WriteString("order book of ")
+	msg.WriteString(e.Symbol)
+	msg.WriteString(" has no liquidity for ")
+	msg.WriteString(e.Side)
+	msg.WriteString(" orders.")
+
+	return msg.String()
+}
+
+func NewEmptyBookError(symbol, side string) EmptyBookError {
+	return EmptyBookError{Symbol: symbol, Side: side}
+}
+
 type NoPriceError struct {
 	currency string
 }
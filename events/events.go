@@ -68,6 +68,95 @@ func NewEditOrder(cancelID exchange.OrderID, newOrder exchange.Order) *EditOrder
 	}
 }
 
+// OCO places a group of one-cancels-other orders. As soon as one of the
+// orders is executed, the connector cancels the rest of the group.
+type OCO struct {
+	orders []exchange.Order
+}
+
+// Occur places the orders that wait in the book before the orders executed
+// on placement, e.g. market orders, so that an executed order cancels the rest
+// of the group. If an order cannot be placed, the placed ones are cancelled.
+func (o *OCO) Occur(connector exchange.Connector) error {
+	waiting := make([]exchange.Order, 0, len(o.orders))
+	immediate := make([]exchange.Order, 0, len(o.orders))
+
+	for _, order := range o.orders {
+		if waitsInBook(order) {
+			waiting = append(waiting, order)
+		} else {
+			immediate = append(immediate, order)
+		}
+	}
+
+	orders := append(waiting, immediate...)
+
+	for i, order := range orders {
+		if err := connector.PlaceOrder(order); err != nil {
+			for _, placed := range orders[:i] {
+				_ = connector.CancelOrder(placed.ID())
+			}
+
+			return fmt.Errorf("error placing OCO order: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// waitsInBook reports whether the order is not executed as soon as it is placed.
+func waitsInBook(order exchange.Order) bool {
+	tif := order.TimeInForce()
+
+	return order.Type() != exchange.Market && tif != exchange.IOC && tif != exchange.FOK
+}
+
+// Orders returns the linked orders of the group.
+func (o *OCO) Orders() []exchange.Order { return o.orders }
+
+func NewOCO(orders ...exchange.Order) *OCO {
+	return &OCO{orders: exchange.LinkOCO(orders...)}
+}
+
+// Bracket places an entry order together with its stop-loss and take-profit orders.
+// The exit orders become active only after the entry order is filled and form
+// a one-cancels-other group, so closing the position by one of them cancels the other.
+type Bracket struct {
+	entry exchange.Order
+	exits []exchange.Order
+}
+
+// Occur places the exit orders before the entry order, so that a market entry
+// order filled immediately activates them. If the entry order cannot be placed,
+// the exit orders are cancelled.
+func (b *Bracket) Occur(connector exchange.Connector) error {
+	for _, exit := range b.exits {
+		if err := connector.PlaceOrder(exit); err != nil {
+			return fmt.Errorf("error placing bracket exit order: %w", err)
+		}
+	}
+
+	if err := connector.PlaceOrder(b.entry); err != nil {
+		for _, exit := range b.exits {
+			_ = connector.CancelOrder(exit.ID())
+		}
+
+		return fmt.Errorf("error placing bracket entry order: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Bracket) Entry() exchange.Order   { return b.entry }
+func (b *Bracket) Exits() []exchange.Order { return b.exits }
+
+func NewBracket(entry, stopLoss, takeProfit exchange.Order) *Bracket {
+	return &Bracket{
+		entry: entry,
+		exits: exchange.LinkBracket(entry, stopLoss, takeProfit),
+	}
+}
+
 // Sequential represents a collection of events that are meant to be executed
 // in sequence. Each event in the actions slice is executed in order, and
 // if any event returns an error, the sequential execution is stopped and the error
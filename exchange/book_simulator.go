@@ -0,0 +1,359 @@
+package exchange
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/quick-trade/xoney/common"
+	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/errors"
+	"github.com/quick-trade/xoney/internal"
+)
+
+// bookFeed replays the recorded updates of the order book of a symbol.
+type bookFeed struct {
+	updates []BookUpdate
+	next    int // Index of the first update not yet applied.
+	book    *OrderBook
+}
+
+// levelState is the state of the book at the price of a resting order.
+type levelState struct {
+	amount float64 // Amount resting at the price of the order.
+	best   bool    // Whether the price was the best on its side of the book.
+}
+
+// OrderBookSimulator executes orders against recorded L2 order books instead of
+// candles. The updates of the book of a symbol are replayed up to the close of
+// every candle of the symbol, candles are still used to value the portfolio.
+// Symbols without a recorded book are executed by candles as in the MarginSimulator.
+//
+// Market orders and the marketable part of limit orders take liquidity level by
+// level, the part that cannot be matched expires. Resting limit orders join the
+// queue at the back of their price level. A decrease of the best level is treated
+// as executions that consume the queue ahead of the order first; decreases of
+// other levels are cancellations that can only shorten the queue. A resting order
+// is filled entirely once the opposite side of the book reaches its price.
+// Conditional orders are triggered by the mid price.
+type OrderBookSimulator struct {
+	MarginSimulator
+	feeds  map[data.Symbol]*bookFeed // Recorded books by symbol.
+	queues map[OrderID]float64       // Amount ahead of resting limit orders in their queues.
+}
+
+// NewOrderBookSimulator creates an OrderBookSimulator replaying the recorded
+// book updates of the symbols.
+func NewOrderBookSimulator(
+	portfolio common.Portfolio,
+	commission float64,
+	books map[data.Symbol][]BookUpdate,
+	options ...SimulatorOption,
+) *OrderBookSimulator {
+	simulator := &OrderBookSimulator{
+		MarginSimulator: NewMarginSimulator(portfolio, commission, options...),
+		feeds:           make(map[data.Symbol]*bookFeed, len(books)),
+		queues:          make(map[OrderID]float64, internal.DefaultCapacity),
+	}
+
+	for symbol, updates := range books {
+		simulator.feeds[symbol] = &bookFeed{updates: updates, book: NewOrderBook()}
+	}
+
+	return simulator
+}
+
+// Book returns the current order book of the symbol.
+func (b *OrderBookSimulator) Book(symbol data.Symbol) (*OrderBook, bool) {
+	feed, ok := b.feeds[symbol]
+	if !ok {
+		return nil, false
+	}
+
+	return &OrderBook{bids: feed.book.Bids(), asks: feed.book.Asks()}, true
+}
+
+// QueuePosition returns the amount resting ahead of the limit order in its queue.
+func (b *OrderBookSimulator) QueuePosition(id OrderID) (float64, bool) {
+	queue, ok := b.queues[id]
+
+	return queue, ok
+}
+
+// PlaceOrder matches market and limit orders against the book of their symbol.
+// Conditional orders and orders with a parent wait as in the MarginSimulator.
+// Market orders are rejected if the opposite side of the book is empty,
+// e.g. before the first update of the book is replayed.
+func (b *OrderBookSimulator) PlaceOrder(order Order) error {
+	feed, ok := b.feeds[order.symbol]
+	if !ok || order.parent != 0 || order.IsConditional() {
+		return b.MarginSimulator.PlaceOrder(order)
+	}
+
+	if b.groupExecuted(order) {
+		return nil
+	}
+
+	order, err := b.applyRules(order)
+	if err != nil {
+		return err
+	}
+
+	if order.orderType == Market && len(*feed.book.opposite(order.side)) == 0 {
+		return b.reject(order, fmt.Errorf("error executing order: %w",
+			errors.NewEmptyBookError(order.symbol.String(), string(order.side))))
+	}
+
+	if err := b.validMargin(order); err != nil {
+		return b.reject(order, fmt.Errorf("error validating order: %w", err))
+	}
+
+	b.statuses[order.ID()] = Open
+
+	return b.execute(order, feed.book)
+}
+
+// execute matches the order with the opposite side of the book up to its limit
+// price as a taker. The remaining amount of a limit order rests in the book,
+// the remaining amount of other orders expires.
+func (b *OrderBookSimulator) execute(order Order, book *OrderBook) error {
+	resting := order.orderType == Limit && !order.immediate()
+
+	limit := order.price
+	if order.orderType != Limit {
+		limit = math.Inf(1)
+		if order.side == Sell {
+			limit = math.Inf(-1)
+		}
+	}
+
+	if order.tif == FOK && book.liquidity(order.side, limit) < order.Remaining() {
+		b.report(order, Expired)
+
+		return nil
+	}
+
+	for _, level := range book.take(order.side, limit, order.Remaining()) {
+		order.filled += level.Amount
+
+		if err := b.fill(order.withPrice(level.Price).withAmount(level.Amount), Taker); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case order.Remaining() <= 0:
+		b.afterExecution(order)
+
+		return nil
+	case order.filled > 0:
+		b.report(order, PartiallyFilled)
+	}
+
+	if !resting {
+		b.report(order, Expired)
+
+		return nil
+	}
+
+	b.queues[order.ID()] = book.amount(order.side, order.price)
+	b.PlaceLimitOrder(order)
+
+	return nil
+}
+
+// UpdatePrice replays the book updates of the candle symbol up to the close
+// of the candle and then updates the prices used to value the portfolio.
+func (b *OrderBookSimulator) UpdatePrice(candle data.InstrumentCandle) error {
+	symbol := candle.Symbol()
+
+	feed, ok := b.feeds[symbol]
+	if !ok {
+		return b.MarginSimulator.UpdatePrice(candle)
+	}
+
+	previous := b.now
+
+	err := b.replay(symbol, feed, candle.TimeClose)
+
+	b.now = previous
+	b.observe(candle)
+
+	if err != nil {
+		return err
+	}
+
+	if err := b.checkLiquidation(candle); err != nil {
+		return fmt.Errorf("liquidation failed: %w", err)
+	}
+
+	return nil
+}
+
+// replay applies the updates of the book up to the moment
+// and matches the pending orders after every update.
+func (b *OrderBookSimulator) replay(symbol data.Symbol, feed *bookFeed, until time.Time) error {
+	for feed.next < len(feed.updates) && !feed.updates[feed.next].Time.After(until) {
+		update := feed.updates[feed.next]
+		feed.next++
+
+		b.now = update.Time
+		before := b.levels(symbol, feed.book)
+
+		feed.book.Apply(update)
+
+		if err := b.match(symbol, feed.book, before); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// levels records the state of the book at the prices of the resting limit
+// orders of the symbol. Orders that joined the book without the queue
+// position, e.g. activated children, are put at the back of the queue.
+func (b *OrderBookSimulator) levels(symbol data.Symbol, book *OrderBook) map[OrderID]levelState {
+	states := make(map[OrderID]levelState)
+
+	for _, order := range b.limitOrders.heap.Members {
+		if order.symbol != symbol || order.orderType != Limit {
+			continue
+		}
+
+		amount := book.amount(order.side, order.price)
+
+		if _, ok := b.queues[order.ID()]; !ok {
+			b.queues[order.ID()] = amount
+		}
+
+		top, exists := best(*book.side(order.side))
+		states[order.ID()] = levelState{
+			amount: amount,
+			best:   !exists || !acceptable(order.side, top.Price, order.price) || top.Price == order.price,
+		}
+	}
+
+	return states
+}
+
+// match matches the pending orders of the symbol with the updated book.
+func (b *OrderBookSimulator) match(symbol data.Symbol, book *OrderBook, before map[OrderID]levelState) error {
+	var err error
+
+	executed := make([]Order, 0)
+	triggered := make([]Order, 0)
+	closedGroups := make(map[OrderID]struct{})
+	mid, hasMid := book.Mid()
+
+	b.limitOrders.heap.Filter(func(order *Order) bool {
+		if order.symbol != symbol || internal.Contains(closedGroups, order.group) {
+			return true
+		}
+
+		if b.expireIfNeeded(*order, order.expiredAt(b.now)) {
+			delete(b.queues, order.ID())
+
+			return false
+		}
+
+		if order.IsConditional() {
+			if !hasMid || !order.Triggered(mid, mid) {
+				if order.orderType == TrailingStop && hasMid {
+					order.trail(mid, mid)
+				}
+
+				return true
+			}
+
+			triggered = append(triggered, *order)
+		} else {
+			done, matchErr := b.matchResting(order, book, before[order.ID()])
+			if matchErr != nil {
+				err = matchErr
+			}
+
+			if !done {
+				return true
+			}
+
+			delete(b.queues, order.ID())
+			executed = append(executed, *order)
+		}
+
+		if order.group != 0 {
+			closedGroups[order.group] = struct{}{}
+		}
+
+		return false
+	})
+
+	for _, order := range executed {
+		b.afterExecution(order)
+	}
+
+	for _, order := range triggered {
+		if order.orderType == StopLimit {
+			order.activate()
+		}
+
+		if execErr := b.execute(order, book); execErr != nil {
+			err = execErr
+		}
+	}
+
+	return err
+}
+
+// matchResting fills a resting limit order as a maker if the opposite side of
+// the book reached its price or the executions at its level consumed the queue
+// ahead of it. It reports whether the order has been filled entirely.
+func (b *OrderBookSimulator) matchResting(order *Order, book *OrderBook, before levelState) (bool, error) {
+	remaining := order.Remaining()
+	quantity := 0.0
+
+	if top, ok := best(*book.opposite(order.side)); ok && acceptable(order.side, order.price, top.Price) {
+		quantity = remaining
+	} else {
+		queue := b.queues[order.ID()]
+		amount := book.amount(order.side, order.price)
+
+		if decrease := before.amount - amount; before.best && decrease > 0 {
+			quantity = math.Min(remaining, math.Max(0, decrease-queue))
+			queue -= decrease
+		}
+
+		b.queues[order.ID()] = math.Max(0, math.Min(queue, amount))
+	}
+
+	if quantity <= 0 {
+		return false, nil
+	}
+
+	order.filled += quantity
+
+	err := b.fill(order.withAmount(quantity), Maker)
+
+	if quantity < remaining {
+		b.report(*order, PartiallyFilled)
+
+		return false, err
+	}
+
+	return true, err
+}
+
+func (b *OrderBookSimulator) Cleanup() error {
+	if err := b.MarginSimulator.Cleanup(); err != nil {
+		return err
+	}
+
+	for _, feed := range b.feeds {
+		feed.next = 0
+		feed.book = NewOrderBook()
+	}
+
+	b.queues = make(map[OrderID]float64, internal.DefaultCapacity)
+
+	return nil
+}
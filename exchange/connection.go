@@ -3,6 +3,8 @@ package exchange
 import (
 	"fmt"
 	"math"
+	"sort"
+	"time"
 
 	"github.com/quick-trade/xoney/common"
 	"github.com/quick-trade/xoney/common/data"
@@ -40,6 +42,18 @@ func (o *OrderHeap) RemoveByID(id OrderID) error {
 	return o.heap.RemoveAt(index)
 }
 
+// take removes the order with the given id from the heap and returns it.
+func (o *OrderHeap) take(id OrderID) (Order, error) {
+	index, err := o.IndexByID(id)
+	if err != nil {
+		return Order{}, err
+	}
+
+	order := o.heap.Members[index]
+
+	return order, o.heap.RemoveAt(index)
+}
+
 // newOrderHeap creates a new OrderHeap with the specified initial capacity.
 // This allows for preallocation of memory to improve performance.
 func newOrderHeap(capacity int) OrderHeap {
@@ -70,6 +84,10 @@ type Connector interface {
 	PlaceOrder(order Order) error                                                  // Places a new order on the exchange.
 	CancelOrder(id OrderID) error                                                  // Cancels an existing order using its ID.
 	CancelAllOrders() error                                                        // Cancels all existing orders.
+	OpenOrders() []Order                                                           // Lists orders that are waiting for execution.
+	OrderStatus(id OrderID) (OrderStatus, error)                                   // Retrieves the current status of an order.
+	FillStream() <-chan Fill                                                       // Streams executions of orders.
+	OrderUpdates() <-chan OrderUpdate                                              // Streams changes of order statuses.
 	Transfer(quantity float64, currency data.Currency, target data.Exchange) error // Transfers a quantity of currency to a target exchange.
 	Portfolio() common.Portfolio                                                   // Retrieves the current state of the portfolio.
 	SellAll() error                                                                // Executes the sale of all assets in the portfolio.
@@ -85,26 +103,97 @@ type Simulator interface {
 	Cleanup() error                                 // Typically used to reset the simulation to its initial state.
 	Total() (float64, error)                        // Calculates the total balance of the portfolio.
 	UpdatePrice(candle data.InstrumentCandle) error // Updates the price based on a new candle data.
+	Fills() []Fill                                  // Returns all executions since the start of the simulation.
 }
 
 // MarginSimulator is a structure used for testing trading strategies with
 // margin trading capabilities. It allows for the simulation of leveraged
 // and short positions.
 type MarginSimulator struct {
-	prices         map[data.Currency]float64 // Current simulated prices for each currency.
-	portfolio      common.Portfolio          // The trading portfolio including current holdings.
-	startPortfolio common.Portfolio          // The portfolio at the start of the simulation to compare against.
-	limitOrders    OrderHeap                 // Heap of limit orders to manage order execution.
-	commission     float64                   // Commission fees for executing trades within the simulator.
+	prices         map[data.Currency]float64   // Current prices of the currencies in the main currency.
+	graph          *common.PriceGraph          // Last prices of the traded pairs used to derive cross rates.
+	portfolio      common.Portfolio            // The trading portfolio including current holdings.
+	startPortfolio common.Portfolio            // The portfolio at the start of the simulation to compare against.
+	limitOrders    OrderHeap                   // Heap of limit and conditional orders waiting for execution.
+	children       OrderHeap                   // Heap of orders waiting for their parent orders to be filled.
+	fees           *FeeSchedule                // Commission fees for executing trades within the simulator.
+	slippage       SlippageModel               // Price impact model for orders executed against the market.
+	participation  float64                     // Maximum share of the candle volume filled by limit orders, zero if unlimited.
+	margin         *marginRules                // Margin requirements, nil if borrowing is not limited.
+	borrowRates    map[data.Currency]float64   // Annualized interest rates on negative balances.
+	settlement     func(order Order) error     // Replaces the exchange of traded quantities if set.
+	rules          *RulesRegistry              // Trading rules of the instruments, nil if orders are not restricted.
+	autoRound      bool                        // Whether orders are rounded to the trading rules instead of rejected.
+	path           IntrabarPath                // Assumed sequence of prices within a candle.
+	costs          Costs                       // Accumulated trading costs in the main currency.
+	candles        map[data.Symbol]data.Candle // Last processed candle of each symbol.
+	now            time.Time                   // Close time of the last processed candle.
+	updates        []OrderUpdate               // Order updates not yet consumed through OrderUpdates.
+	fills          []Fill                      // Journal of all executions.
+	streamed       int                         // Number of fills already sent through FillStream.
+	statuses       map[OrderID]OrderStatus     // Last known status of every placed order.
+	executedGroups map[OrderID]bool            // One-cancels-other groups that already have an executed order.
 }
 
+// CancelOrder cancels an active or a waiting order. Cancelling an order
+// also cancels all orders waiting for it to be filled.
 func (s *MarginSimulator) CancelOrder(id OrderID) error {
-	return s.limitOrders.RemoveByID(id)
+	if child, err := s.children.take(id); err == nil {
+		s.report(child, Canceled)
+
+		return nil
+	}
+
+	order, err := s.limitOrders.take(id)
+	if err != nil {
+		return err
+	}
+
+	s.report(order, Canceled)
+	s.cancelChildren(id)
+
+	return nil
 }
 
+// PlaceOrder executes a market order immediately and keeps any other order
+// until its price is reached. Orders with a parent are held inactive until
+// the parent order is filled. Orders violating the trading rules or, if margin
+// requirements are set, exceeding the buying power are rejected.
 func (s *MarginSimulator) PlaceOrder(order Order) error {
+	if s.groupExecuted(order) {
+		return nil
+	}
+
+	order, err := s.applyRules(order)
+	if err != nil {
+		return err
+	}
+
+	return s.place(order)
+}
+
+// place places the order that already complies with the trading rules.
+func (s *MarginSimulator) place(order Order) error {
+	s.statuses[order.ID()] = Open
+
+	if order.parent != 0 {
+		s.children.heap.Add(order)
+
+		return nil
+	}
+
+	if err := s.validMargin(order); err != nil {
+		return s.reject(order, fmt.Errorf("error validating order: %w", err))
+	}
+
 	if order.orderType == Market {
-		return s.executeMarketOrder(order)
+		if err := s.executeTaker(order, s.lastCandle(order)); err != nil {
+			return err
+		}
+
+		s.afterExecution(order)
+
+		return nil
 	}
 
 	s.PlaceLimitOrder(order)
@@ -112,7 +201,30 @@ func (s *MarginSimulator) PlaceOrder(order Order) error {
 	return nil
 }
 
-func (s *MarginSimulator) executeMarketOrder(order Order) error {
+// executeTaker executes an order against the market at its price
+// moved by the slippage model.
+func (s *MarginSimulator) executeTaker(order Order, candle data.Candle) error {
+	impact := s.slippage.Slippage(order, candle)
+	price := slippedPrice(order.side, order.price, impact)
+
+	cost := math.Abs(price-order.price) * order.amount
+	s.costs[SlippageCost] += s.valueInMain(order.symbol.Quote(), cost)
+
+	return s.fill(order.withPrice(price), Taker)
+}
+
+// fill executes the amount of the order at the order price
+// and charges the fee for the given liquidity.
+func (s *MarginSimulator) fill(order Order, liquidity Liquidity) error {
+	feeCurrency, fee := s.chargeFee(order, liquidity)
+	s.fees.register(s.now, s.valueInMain(order.symbol.Quote(), order.amount*order.price))
+
+	return s.settle(order, liquidity, feeCurrency, fee)
+}
+
+// settle records the execution of the order in the journal
+// and exchanges the traded quantities.
+func (s *MarginSimulator) settle(order Order, liquidity Liquidity, feeCurrency data.Currency, fee float64) error {
 	baseQuantity := order.amount
 	quoteQuantity := baseQuantity * order.price
 
@@ -120,8 +232,21 @@ func (s *MarginSimulator) executeMarketOrder(order Order) error {
 	quote := symbol.Quote()
 	base := symbol.Base()
 
-	commission := s.commission * quoteQuantity
-	s.portfolio.Decrease(quote, commission)
+	s.fills = internal.Append(s.fills, Fill{
+		OrderID:     order.internalID,
+		Symbol:      symbol,
+		Side:        order.side,
+		Price:       order.price,
+		Amount:      baseQuantity,
+		Fee:         fee,
+		FeeCurrency: feeCurrency,
+		Liquidity:   liquidity,
+		Time:        s.now,
+	})
+
+	if s.settlement != nil {
+		return s.settlement(order)
+	}
 
 	if order.side == Buy {
 		return s.executeBuyOrder(base, quote, baseQuantity, quoteQuantity)
@@ -148,25 +273,271 @@ func (s *MarginSimulator) PlaceLimitOrder(order Order) {
 	s.limitOrders.heap.Add(order)
 }
 
-func (s *MarginSimulator) updateLimits(symbol data.Symbol, high, low float64) error {
+func (s *MarginSimulator) updateLimits(candle data.InstrumentCandle) error {
 	var err error
 
+	symbol := candle.Symbol()
+	opened := candle.TimeClose.Add(-candle.Timeframe().Duration)
+
+	executed := make([]Order, 0)
+	closedGroups := make(map[OrderID]struct{})
+	available := s.availableVolume(candle.Volume)
+	paths := s.scheduleOrders(candle)
+
 	s.limitOrders.heap.Filter(func(order *Order) bool {
-		if order.symbol == symbol && order.CrossesPrice(high, low) {
-			execErr := s.executeMarketOrder(*order)
-			if execErr != nil {
-				err = execErr
+		if order.symbol != symbol {
+			return !s.expireIfNeeded(*order, order.expiredAt(candle.TimeClose))
+		}
+
+		// Only one order of a one-cancels-other group can be executed.
+		if internal.Contains(closedGroups, order.group) {
+			return true
+		}
+
+		if s.expireIfNeeded(*order, order.expiredAt(opened)) {
+			return false
+		}
+
+		done, execErr := s.processPending(order, candle.Candle, paths[order.ID()], &available)
+		if execErr != nil {
+			err = execErr
+		}
+
+		if done {
+			executed = append(executed, *order)
+
+			if order.group != 0 {
+				closedGroups[order.group] = struct{}{}
 			}
 
 			return false
 		}
 
-		return true
+		return !s.expireIfNeeded(*order, order.immediate() || order.expiredAt(candle.TimeClose))
 	})
 
+	s.children.heap.Filter(func(child *Order) bool {
+		return !s.expireIfNeeded(*child, child.expiredAt(candle.TimeClose))
+	})
+
+	for _, order := range executed {
+		s.afterExecution(order)
+	}
+
 	return err
 }
 
+// expireIfNeeded reports the order as expired if the condition holds.
+func (s *MarginSimulator) expireIfNeeded(order Order, expired bool) bool {
+	if expired {
+		s.report(order, Expired)
+	}
+
+	return expired
+}
+
+// reject reports the order as rejected and returns the reason of the rejection
+// wrapped in errors.OrderRejectedError.
+func (s *MarginSimulator) reject(order Order, reason error) error {
+	s.report(order, Rejected)
+
+	return errors.NewOrderRejectedError(uint64(order.ID()), reason)
+}
+
+func (s *MarginSimulator) report(order Order, status OrderStatus) {
+	s.statuses[order.ID()] = status
+	s.updates = internal.Append(s.updates, *NewOrderUpdate(order, status, s.now))
+}
+
+// OrderUpdates returns the order updates that occurred since the previous call:
+// executions, cancellations, expirations and rejections of orders.
+// The returned channel is closed.
+func (s *MarginSimulator) OrderUpdates() <-chan OrderUpdate {
+	updates := make(chan OrderUpdate, len(s.updates))
+	defer close(updates)
+
+	for _, update := range s.updates {
+		updates <- update
+	}
+
+	s.updates = s.updates[:0]
+
+	return updates
+}
+
+// groupExecuted reports the order as cancelled if another order of its
+// one-cancels-other group has already been executed, e.g. a market order
+// of the group placed before it.
+func (s *MarginSimulator) groupExecuted(order Order) bool {
+	if order.group == 0 || !s.executedGroups[order.group] {
+		return false
+	}
+
+	s.report(order, Canceled)
+
+	return true
+}
+
+// afterExecution reports the order as filled, cancels its one-cancels-other
+// siblings and activates the orders that were waiting for it.
+func (s *MarginSimulator) afterExecution(order Order) {
+	s.report(order, Filled)
+
+	if group := order.group; group != 0 {
+		s.executedGroups[group] = true
+
+		sameGroup := func(other *Order) bool {
+			if other.group != group {
+				return true
+			}
+
+			s.report(*other, Canceled)
+
+			return false
+		}
+
+		s.limitOrders.heap.Filter(sameGroup)
+		s.children.heap.Filter(sameGroup)
+	}
+
+	s.children.heap.Filter(func(child *Order) bool {
+		if child.parent != order.ID() {
+			return true
+		}
+
+		s.PlaceLimitOrder(*child)
+
+		return false
+	})
+}
+
+func (s *MarginSimulator) cancelChildren(parent OrderID) {
+	s.children.heap.Filter(func(child *Order) bool {
+		if child.parent != parent {
+			return true
+		}
+
+		s.report(*child, Canceled)
+
+		return false
+	})
+}
+
+// scheduleOrders sorts the pending orders so that the orders of the candle
+// symbol are processed in the order the intrabar path reaches them, and
+// returns the path on which every order of the symbol is reached.
+func (s *MarginSimulator) scheduleOrders(candle data.InstrumentCandle) map[OrderID]pricePath {
+	symbol := candle.Symbol()
+	members := s.limitOrders.heap.Members
+
+	moments := make(map[OrderID]float64, len(members))
+	paths := make(map[OrderID]pricePath, len(members))
+
+	for _, order := range members {
+		moments[order.ID()] = math.Inf(1)
+
+		if order.symbol == symbol {
+			moments[order.ID()], paths[order.ID()] = s.schedule(order, candle.Candle)
+		}
+	}
+
+	// The heap is filtered from the end, so the first reached order is put last.
+	sort.SliceStable(members, func(i, j int) bool {
+		return moments[members[i].ID()] > moments[members[j].ID()]
+	})
+
+	return paths
+}
+
+// processPending checks a pending order against the candle and executes it if
+// its price is reached. Limit orders are filled within the volume available
+// in the candle. A StopLimit order is filled only if the path crosses its limit
+// price after the stop price is reached. It reports whether the order has been
+// filled entirely.
+func (s *MarginSimulator) processPending(order *Order, candle data.Candle, path pricePath, available *float64) (bool, error) {
+	if order.orderType == TrailingStop {
+		return s.processTrailing(order, candle)
+	}
+
+	high, low := candle.High, candle.Low
+
+	if order.IsConditional() {
+		level, rising := order.level()
+
+		moment, triggered := path.reach(level, rising)
+		if !triggered {
+			return false, nil
+		}
+
+		if order.orderType != StopLimit {
+			return true, s.executeTaker(order.withPrice(order.triggerPrice(candle.Open)), candle)
+		}
+
+		order.activate()
+
+		high, low = path.extremes(moment)
+	}
+
+	if !order.CrossesPrice(high, low) {
+		return false, nil
+	}
+
+	return s.fillLimit(order, available)
+}
+
+// fillLimit fills the remaining amount of a limit order as far as the available
+// volume allows and reports whether the order has been filled entirely.
+// FOK orders are not filled if the volume is not sufficient for the whole amount.
+func (s *MarginSimulator) fillLimit(order *Order, available *float64) (bool, error) {
+	remaining := order.Remaining()
+	quantity := math.Min(remaining, *available)
+
+	if quantity <= 0 || (order.tif == FOK && quantity < remaining) {
+		return false, nil
+	}
+
+	*available -= quantity
+	order.filled += quantity
+
+	err := s.fill(order.withAmount(quantity), Maker)
+
+	if quantity < remaining {
+		s.report(*order, PartiallyFilled)
+
+		return false, err
+	}
+
+	return true, err
+}
+
+// availableVolume returns the volume of a candle that can be filled by limit
+// orders according to the maximum participation rate.
+func (s *MarginSimulator) availableVolume(volume float64) float64 {
+	if s.participation <= 0 {
+		return math.Inf(1)
+	}
+
+	return s.participation * volume
+}
+
+// processTrailing checks a TrailingStop order against the stop price set before
+// the candle, and then moves the stop price after the candle extreme. Since the
+// close comes after any extreme, the order is also executed at the new stop
+// price if the candle closes beyond it.
+func (s *MarginSimulator) processTrailing(order *Order, candle data.Candle) (bool, error) {
+	if order.Triggered(candle.High, candle.Low) {
+		return true, s.executeTaker(order.withPrice(order.triggerPrice(candle.Open)), candle)
+	}
+
+	order.trail(candle.High, candle.Low)
+
+	if order.Triggered(candle.Close, candle.Close) {
+		return true, s.executeTaker(order.withPrice(order.stopPrice), candle)
+	}
+
+	return false, nil
+}
+
 func (s *MarginSimulator) Transfer(quantity float64, currency data.Currency, target data.Exchange) error {
 	if s.portfolio.Balance(currency) < quantity {
 		return errors.NewNotEnoughFundsError(currency.String(), quantity)
@@ -180,45 +551,171 @@ func (s *MarginSimulator) Transfer(quantity float64, currency data.Currency, tar
 	return nil
 }
 
+// UpdatePrice executes the pending orders triggered within the candle and then
+// checks the maintenance margin of what is left of the positions, so the stops
+// and targets close the positions before they are liquidated.
 func (s *MarginSimulator) UpdatePrice(candle data.InstrumentCandle) error {
+	s.observe(candle)
+
+	err := s.updateLimits(candle)
+
+	if liquidationErr := s.checkLiquidation(candle); liquidationErr != nil {
+		return fmt.Errorf("liquidation failed: %w", liquidationErr)
+	}
+
+	return err
+}
+
+// observe updates the prices and the current time of the simulation,
+// accrues the interest for the elapsed time and drops the traded volume
+// out of the fee window. Currencies not traded against
+// the main currency are priced through intermediate pairs.
+func (s *MarginSimulator) observe(candle data.InstrumentCandle) {
 	symbol := candle.Symbol()
-	base := symbol.Base()
-	quote := symbol.Quote()
 
-	if quote == s.portfolio.MainCurrency() {
-		s.prices[base] = candle.Close
+	s.graph.Update(symbol, candle.Close)
+	s.prices = s.graph.Prices(s.portfolio.MainCurrency())
+
+	s.accrueInterest(candle.TimeClose)
+
+	s.now = candle.TimeClose
+	s.candles[symbol] = candle.Candle
+	s.fees.expire(s.now)
+}
+
+// chargeFee charges the fee for a fill of the order in the currency defined by
+// the fee schedule and returns the fee currency and quantity.
+func (s *MarginSimulator) chargeFee(order Order, liquidity Liquidity) (data.Currency, float64) {
+	rate := s.fees.Rate(liquidity)
+	quote := order.symbol.Quote()
+	quoteFee := rate * order.amount * order.price
+
+	currency, fee := quote, quoteFee
+
+	switch s.fees.Payment() {
+	case FeeInBase:
+		currency, fee = order.symbol.Base(), rate*order.amount
+	case FeeInToken:
+		token := s.fees.Token()
+		if price := s.prices[token]; price > 0 {
+			discounted := s.valueInMain(quote, quoteFee) * (1 - s.fees.tokenDiscount)
+			currency, fee = token, discounted/price
+		}
+	case FeeInQuote:
 	}
 
-	return s.updateLimits(symbol, candle.High, candle.Low)
+	s.portfolio.Decrease(currency, fee)
+	s.costs[CommissionCost] += s.valueInMain(currency, fee)
+
+	return currency, fee
+}
+
+// lastCandle returns the last processed candle of the order symbol. If there is
+// no such candle, a flat candle at the order price is returned.
+func (s *MarginSimulator) lastCandle(order Order) data.Candle {
+	if candle, ok := s.candles[order.symbol]; ok {
+		return candle
+	}
+
+	price := order.price
+
+	return *data.NewCandle(price, price, price, price, 0, s.now)
+}
+
+// valueInMain converts a quantity of the currency into the main currency
+// using the current prices. Currencies without a price are valued at zero.
+func (s *MarginSimulator) valueInMain(currency data.Currency, quantity float64) float64 {
+	if currency == s.portfolio.MainCurrency() {
+		return quantity
+	}
+
+	return quantity * s.prices[currency]
+}
+
+// Fills returns a copy of the journal of all executions since the start of the simulation.
+func (s *MarginSimulator) Fills() []Fill {
+	fills := make([]Fill, len(s.fills))
+	copy(fills, s.fills)
+
+	return fills
+}
+
+// Costs returns the trading costs accumulated since the start of the simulation.
+func (s *MarginSimulator) Costs() Costs {
+	return internal.MapCopy(s.costs)
 }
 
 func (s *MarginSimulator) CancelAllOrders() error {
+	for _, order := range s.OpenOrders() {
+		s.report(order, Canceled)
+	}
+
 	s.limitOrders.heap.Members = make([]Order, 0, internal.DefaultCapacity)
+	s.children.heap.Members = make([]Order, 0, internal.DefaultCapacity)
 
 	return nil
 }
 
+// OpenOrders returns copies of the active orders and the orders
+// waiting for their parent orders to be filled.
+func (s *MarginSimulator) OpenOrders() []Order {
+	orders := make([]Order, 0, s.limitOrders.heap.Len()+s.children.heap.Len())
+	orders = append(orders, s.limitOrders.heap.Members...)
+	orders = append(orders, s.children.heap.Members...)
+
+	return orders
+}
+
+// OrderStatus returns the current status of an order placed in the simulator.
+func (s *MarginSimulator) OrderStatus(id OrderID) (OrderStatus, error) {
+	status, ok := s.statuses[id]
+	if !ok {
+		return "", errors.NewUnknownOrderError(uint64(id))
+	}
+
+	return status, nil
+}
+
+// FillStream returns the executions that occurred since the previous call.
+// The returned channel is closed.
+func (s *MarginSimulator) FillStream() <-chan Fill {
+	fills := make(chan Fill, len(s.fills)-s.streamed)
+	defer close(fills)
+
+	for _, fill := range s.fills[s.streamed:] {
+		fills <- fill
+	}
+
+	s.streamed = len(s.fills)
+
+	return fills
+}
+
 func (s *MarginSimulator) Total() (float64, error) {
-	return s.portfolio.Total(s.prices)
+	return s.portfolio.TotalByGraph(s.graph)
 }
 
 func (s *MarginSimulator) Portfolio() common.Portfolio {
 	return s.portfolio.Copy()
 }
 
+// SellAll closes the positions in all currencies traded directly
+// against the main currency. The positions are closed in the order
+// of the currency names, so that the orders are reproducible.
 func (s *MarginSimulator) SellAll() error {
-	balance := s.portfolio.Assets()
+	main := s.portfolio.MainCurrency()
 
 	var firstErr error
 
-	for currency, price := range s.prices {
-		amount := balance[currency]
+	for _, currency := range sortedCurrencies(s.portfolio.Assets()) {
+		amount := s.portfolio.Balance(currency)
+		price, traded := s.graph.Rate(currency, main)
 
-		if amount == 0 {
+		if amount == 0 || !traded {
 			continue
 		}
 
-		symbol := data.NewSymbolFromCurrencies(currency, s.portfolio.MainCurrency())
+		symbol := data.NewSymbolFromCurrencies(currency, main)
 
 		order, _ := NewOrder(*symbol, Market, orderSideFromBalance(amount), price, math.Abs(amount))
 		err := s.PlaceOrder(*order)
@@ -231,25 +728,37 @@ func (s *MarginSimulator) SellAll() error {
 	return firstErr
 }
 
+// sortedCurrencies returns the currencies of the distribution sorted by name.
+func sortedCurrencies(distribution common.BaseDistribution) []data.Currency {
+	currencies := internal.MapKeys(distribution)
+
+	sort.Slice(currencies, func(i, j int) bool {
+		return currencies[i].String() < currencies[j].String()
+	})
+
+	return currencies
+}
+
+// GetPrices returns the prices of the symbols, derived through intermediate
+// pairs for symbols that were not traded. Both returned channels are closed.
 func (s *MarginSimulator) GetPrices(symbols []data.Symbol) (<-chan SymbolPrice, <-chan error) {
 	prices := make(chan SymbolPrice, len(symbols))
 	defer close(prices)
 
-	err := make(chan error)
+	err := make(chan error, 1)
 	defer close(err)
 
 	for _, symbol := range symbols {
-		if symbol.Quote() != s.portfolio.MainCurrency() {
-			err <- fmt.Errorf("cannot get prices for non-main quote currency, got: %v", symbol.String())
+		price, priceErr := s.graph.Price(symbol.Base(), symbol.Quote())
+		if priceErr != nil {
+			err <- fmt.Errorf("cannot get price of %v: %w", symbol.String(), priceErr)
 
 			return prices, err
 		}
 
-		prices <- *NewSymbolPrice(symbol, s.prices[symbol.Base()])
+		prices <- *NewSymbolPrice(symbol, price)
 	}
 
-	err <- nil
-
 	return prices, err
 }
 
@@ -258,17 +767,106 @@ func (s *MarginSimulator) Cleanup() error {
 	if err != nil {
 		return fmt.Errorf("order cleanup failed: %w", err)
 	}
+
+	s.updates = s.updates[:0]
+	s.costs = make(Costs)
+	s.now = time.Time{}
+	s.fills = s.fills[:0]
+	s.streamed = 0
+	s.statuses = make(map[OrderID]OrderStatus, internal.DefaultCapacity)
+	s.fees.reset()
+
 	return nil
 }
 
-func NewMarginSimulator(portfolio common.Portfolio, commission float64) MarginSimulator {
-	return MarginSimulator{
+// applyRules validates the order against the trading rules or rounds it
+// if auto rounding is enabled. Invalid orders are reported as rejected.
+func (s *MarginSimulator) applyRules(order Order) (Order, error) {
+	if s.rules == nil {
+		return order, nil
+	}
+
+	var err error
+
+	if s.autoRound {
+		order, err = s.rules.Round(order)
+	} else {
+		err = s.rules.Validate(order)
+	}
+
+	if err != nil {
+		return order, s.reject(order, fmt.Errorf("order violates trading rules: %w", err))
+	}
+
+	return order, nil
+}
+
+// SimulatorOption configures optional behaviour of the simulators.
+type SimulatorOption func(*MarginSimulator)
+
+// WithSlippage sets the price impact model for orders executed against the market.
+// By default orders are filled without slippage.
+func WithSlippage(model SlippageModel) SimulatorOption {
+	return func(s *MarginSimulator) {
+		s.slippage = model
+	}
+}
+
+// WithFeeSchedule replaces the flat commission with a fee schedule that
+// distinguishes maker and taker fills and supports volume tiers.
+func WithFeeSchedule(schedule *FeeSchedule) SimulatorOption {
+	return func(s *MarginSimulator) {
+		s.fees = schedule
+	}
+}
+
+// WithMaxParticipation limits the share of the candle volume that limit orders can
+// fill, e.g. 0.1 for 10%. Larger orders are filled partially over several candles,
+// the remaining amount stays in the order book. Candles without volume fill nothing.
+func WithMaxParticipation(rate float64) SimulatorOption {
+	return func(s *MarginSimulator) {
+		s.participation = rate
+	}
+}
+
+// WithTradingRules restricts orders by the trading rules of their instruments.
+// Orders violating the rules are rejected, unless autoRound is set: then the
+// prices are rounded to the tick size and the amount down to the lot size,
+// and only orders below the minimum notional after rounding are rejected.
+func WithTradingRules(rules *RulesRegistry, autoRound bool) SimulatorOption {
+	return func(s *MarginSimulator) {
+		s.rules = rules
+		s.autoRound = autoRound
+	}
+}
+
+// NewMarginSimulator creates a MarginSimulator charging the commission as a fraction
+// of the traded notional for both maker and taker fills.
+func NewMarginSimulator(portfolio common.Portfolio, commission float64, options ...SimulatorOption) MarginSimulator {
+	simulator := MarginSimulator{
 		prices:         make(common.BaseDistribution, internal.DefaultCapacity),
+		graph:          common.NewPriceGraph(),
 		portfolio:      portfolio,
 		startPortfolio: portfolio.Copy(),
 		limitOrders:    newOrderHeap(internal.DefaultCapacity),
-		commission:     commission,
+		children:       newOrderHeap(internal.DefaultCapacity),
+		fees:           NewFlatFeeSchedule(commission, commission),
+		path:           PathNearestExtreme,
+		slippage:       NoSlippage{},
+		costs:          make(Costs),
+		borrowRates:    make(map[data.Currency]float64),
+		candles:        make(map[data.Symbol]data.Candle, internal.DefaultCapacity),
+		updates:        make([]OrderUpdate, 0, internal.DefaultCapacity),
+		fills:          make([]Fill, 0, internal.DefaultCapacity),
+		statuses:       make(map[OrderID]OrderStatus, internal.DefaultCapacity),
+		executedGroups: make(map[OrderID]bool),
 	}
+
+	for _, option := range options {
+		option(&simulator)
+	}
+
+	return simulator
 }
 
 func orderSideFromBalance(balance float64) OrderSide {
@@ -282,24 +880,32 @@ func orderSideFromBalance(balance float64) OrderSide {
 // It only supports long positions and does not allow the use of leverage.
 type SpotSimulator struct{ MarginSimulator }
 
-func NewSpotSimulator(portfolio common.Portfolio, commission float64) SpotSimulator {
+func NewSpotSimulator(portfolio common.Portfolio, commission float64, options ...SimulatorOption) SpotSimulator {
 	return SpotSimulator{
-		MarginSimulator: NewMarginSimulator(portfolio, commission),
+		MarginSimulator: NewMarginSimulator(portfolio, commission, options...),
 	}
 }
 
+// PlaceOrder validates that the portfolio holds enough funds for the order
+// and places it. Orders with a parent are not validated, since the funds
+// for them appear only after the parent order is filled.
 func (s *SpotSimulator) PlaceOrder(order Order) error {
-	if err := s.validOrder(order); err != nil {
-		return fmt.Errorf("error validating order: %w", err)
+	if s.groupExecuted(order) {
+		return nil
 	}
 
-	if order.orderType == Market {
-		return s.executeMarketOrder(order)
+	order, err := s.applyRules(order)
+	if err != nil {
+		return err
 	}
 
-	s.PlaceLimitOrder(order)
+	if order.parent == 0 {
+		if err := s.validOrder(order); err != nil {
+			return s.reject(order, fmt.Errorf("error validating order: %w", err))
+		}
+	}
 
-	return nil
+	return s.place(order)
 }
 
 func (s *SpotSimulator) validOrder(order Order) error {
@@ -0,0 +1,26 @@
+package exchange
+
+// CostKind names a type of trading costs.
+type CostKind string
+
+const (
+	CommissionCost  CostKind = "commission"
+	SlippageCost    CostKind = "slippage"
+	LiquidationCost CostKind = "liquidation"
+	InterestCost    CostKind = "interest"
+	FundingCost     CostKind = "funding"
+)
+
+// Costs maps each kind of trading costs to its accumulated value
+// in the main currency of the portfolio.
+type Costs map[CostKind]float64
+
+// Total returns the sum of all kinds of costs.
+func (c Costs) Total() float64 {
+	total := 0.0
+	for _, cost := range c {
+		total += cost
+	}
+
+	return total
+}
@@ -0,0 +1,169 @@
+package exchange
+
+import (
+	"sort"
+	"time"
+
+	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/errors"
+	"github.com/quick-trade/xoney/internal"
+)
+
+// DefaultFeeWindow is the period of traded volume that determines the fee tier.
+const DefaultFeeWindow = 30 * 24 * time.Hour
+
+// Liquidity tells whether a fill added liquidity to the order book (maker)
+// or took it (taker).
+type Liquidity string
+
+const (
+	Maker Liquidity = "maker" // Fill of a resting limit order.
+	Taker Liquidity = "taker" // Fill of an order executed against the market.
+)
+
+// FeePayment defines the currency in which the fees are charged.
+type FeePayment string
+
+const (
+	FeeInQuote FeePayment = "quote" // Fees are charged in the quote currency of the symbol.
+	FeeInBase  FeePayment = "base"  // Fees are charged in the base currency of the symbol.
+	FeeInToken FeePayment = "token" // Fees are charged in a separate fee token.
+)
+
+// FeeTier holds the commission rates applied when the rolling traded volume
+// reaches MinVolume. Rates are fractions of the traded notional; negative
+// rates are rebates paid to the trader.
+type FeeTier struct {
+	MinVolume float64 // Traded volume in the main currency required for the tier.
+	Maker     float64 // Fee rate for maker fills.
+	Taker     float64 // Fee rate for taker fills.
+}
+
+// Rate returns the fee rate of the tier for the given liquidity.
+func (t FeeTier) Rate(liquidity Liquidity) float64 {
+	if liquidity == Maker {
+		return t.Maker
+	}
+
+	return t.Taker
+}
+
+type volumeRecord struct {
+	moment time.Time
+	volume float64
+}
+
+// FeeSchedule describes how an exchange charges fees. It keeps track of the
+// volume traded during the rolling window to select the fee tier, so every
+// simulator needs its own FeeSchedule.
+type FeeSchedule struct {
+	tiers         []FeeTier      // Tiers sorted by the required volume.
+	window        time.Duration  // Period of the rolling traded volume.
+	payment       FeePayment     // Currency in which the fees are charged.
+	token         data.Currency  // Fee token used with FeeInToken.
+	tokenDiscount float64        // Fee discount when paying with the token, e.g. 0.25 for 25%.
+	history       []volumeRecord // Fills within the rolling window.
+	volume        float64        // Traded volume within the rolling window.
+}
+
+// NewFeeSchedule creates a FeeSchedule with volume tiers. The tier with the
+// lowest required volume is used until the traded volume reaches the next tier.
+// Fees are charged in the quote currency by default.
+func NewFeeSchedule(tiers ...FeeTier) (*FeeSchedule, error) {
+	if len(tiers) == 0 {
+		return nil, errors.NewZeroLengthError("fee tiers")
+	}
+
+	sorted := make([]FeeTier, len(tiers))
+	copy(sorted, tiers)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].MinVolume < sorted[j].MinVolume
+	})
+
+	return &FeeSchedule{
+		tiers:   sorted,
+		window:  DefaultFeeWindow,
+		payment: FeeInQuote,
+		history: make([]volumeRecord, 0, internal.DefaultCapacity),
+	}, nil
+}
+
+// NewFlatFeeSchedule creates a FeeSchedule with a single tier.
+func NewFlatFeeSchedule(maker, taker float64) *FeeSchedule {
+	schedule, _ := NewFeeSchedule(FeeTier{MinVolume: 0, Maker: maker, Taker: taker})
+
+	return schedule
+}
+
+// SetWindow sets the period of the rolling traded volume used to select the tier.
+func (f *FeeSchedule) SetWindow(window time.Duration) {
+	f.window = window
+}
+
+// ChargeInBase makes the fees to be charged in the base currency of the traded symbol.
+func (f *FeeSchedule) ChargeInBase() {
+	f.payment = FeeInBase
+}
+
+// ChargeInToken makes the fees to be charged in the token with the given discount,
+// e.g. 0.25 for 25%. The token must have a price in the main currency, otherwise
+// the fee is charged in the quote currency.
+func (f *FeeSchedule) ChargeInToken(token data.Currency, discount float64) {
+	f.payment = FeeInToken
+	f.token = token
+	f.tokenDiscount = discount
+}
+
+func (f *FeeSchedule) Payment() FeePayment  { return f.payment }
+func (f *FeeSchedule) Token() data.Currency { return f.token }
+
+// Volume returns the traded volume within the rolling window.
+func (f *FeeSchedule) Volume() float64 { return f.volume }
+
+// Tier returns the tier corresponding to the current rolling traded volume.
+func (f *FeeSchedule) Tier() FeeTier {
+	current := f.tiers[0]
+
+	for _, tier := range f.tiers[1:] {
+		if f.volume < tier.MinVolume {
+			break
+		}
+
+		current = tier
+	}
+
+	return current
+}
+
+// Rate returns the current fee rate for the given liquidity.
+func (f *FeeSchedule) Rate(liquidity Liquidity) float64 {
+	return f.Tier().Rate(liquidity)
+}
+
+// register adds a traded volume to the rolling window ending at the moment.
+func (f *FeeSchedule) register(moment time.Time, volume float64) {
+	f.history = internal.Append(f.history, volumeRecord{moment: moment, volume: volume})
+	f.volume += volume
+
+	f.expire(moment)
+}
+
+// expire drops the traded volume that is out of the rolling window ending at the moment.
+func (f *FeeSchedule) expire(moment time.Time) {
+	start := moment.Add(-f.window)
+	expired := 0
+
+	for expired < len(f.history) && !f.history[expired].moment.After(start) {
+		f.volume -= f.history[expired].volume
+		expired++
+	}
+
+	f.history = f.history[expired:]
+}
+
+// reset clears the traded volume.
+func (f *FeeSchedule) reset() {
+	f.history = f.history[:0]
+	f.volume = 0
+}
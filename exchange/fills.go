@@ -0,0 +1,27 @@
+package exchange
+
+import (
+	"time"
+
+	"github.com/quick-trade/xoney/common/data"
+)
+
+// Fill is a record of an order execution. An order filled partially
+// produces a separate Fill for every execution.
+type Fill struct {
+	OrderID     OrderID       // ID of the executed order.
+	Symbol      data.Symbol   // Traded symbol.
+	Side        OrderSide     // Side of the executed order.
+	Price       float64       // Execution price including slippage.
+	Amount      float64       // Executed amount in the base currency.
+	Fee         float64       // Charged fee, negative for rebates.
+	FeeCurrency data.Currency // Currency in which the fee is charged.
+	Liquidity   Liquidity     // Whether the fill was a maker or a taker one.
+	Time        time.Time     // Moment of the execution.
+}
+
+// IsMaker reports whether the fill added liquidity to the order book.
+func (f Fill) IsMaker() bool { return f.Liquidity == Maker }
+
+// Notional returns the executed value in the quote currency.
+func (f Fill) Notional() float64 { return f.Price * f.Amount }
@@ -0,0 +1,73 @@
+package exchange
+
+import (
+	"sort"
+	"time"
+
+	"github.com/quick-trade/xoney/errors"
+)
+
+// DefaultFundingInterval is the period between funding settlements
+// of most perpetual swaps.
+const DefaultFundingInterval = 8 * time.Hour
+
+// FundingRate is the funding rate of a perpetual swap effective from Time.
+// With a positive rate long positions pay short positions.
+type FundingRate struct {
+	Time time.Time
+	Rate float64
+}
+
+// FundingRates is a series of funding rates settled at a fixed interval.
+// Settlements occur at multiples of the interval, e.g. at 00:00, 08:00
+// and 16:00 UTC with the default interval.
+type FundingRates struct {
+	interval time.Duration
+	rates    []FundingRate // Rates sorted by time.
+}
+
+// NewFundingRates creates a series of funding rates settled at the interval.
+func NewFundingRates(interval time.Duration, rates ...FundingRate) (*FundingRates, error) {
+	if interval <= 0 {
+		return nil, errors.NewIncorrectDurationError(interval)
+	}
+
+	sorted := make([]FundingRate, len(rates))
+	copy(sorted, rates)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Time.Before(sorted[j].Time)
+	})
+
+	return &FundingRates{
+		interval: interval,
+		rates:    sorted,
+	}, nil
+}
+
+func (f *FundingRates) Interval() time.Duration { return f.interval }
+
+// Rate returns the last funding rate effective at the moment,
+// zero if the series starts later.
+func (f *FundingRates) Rate(moment time.Time) float64 {
+	index := sort.Search(len(f.rates), func(i int) bool {
+		return f.rates[i].Time.After(moment)
+	})
+
+	if index == 0 {
+		return 0
+	}
+
+	return f.rates[index-1].Rate
+}
+
+// settlements returns the settlement moments within the period (from, to].
+func (f *FundingRates) settlements(from, to time.Time) []time.Time {
+	moments := make([]time.Time, 0)
+
+	for moment := from.Truncate(f.interval).Add(f.interval); !moment.After(to); moment = moment.Add(f.interval) {
+		moments = append(moments, moment)
+	}
+
+	return moments
+}
@@ -0,0 +1,477 @@
+package exchange
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/quick-trade/xoney/common"
+	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/errors"
+	"github.com/quick-trade/xoney/internal"
+)
+
+// DefaultMaintenanceMargin is the maintenance margin ratio of the FuturesSimulator.
+const DefaultMaintenanceMargin = 0.005
+
+// MarginMode defines how the collateral of futures positions is shared.
+type MarginMode string
+
+const (
+	CrossMargin    MarginMode = "cross"    // All positions share the collateral of the portfolio.
+	IsolatedMargin MarginMode = "isolated" // Every position is backed only by the margin allocated to it.
+)
+
+// Position is a position in linear perpetual contracts of a symbol.
+// The size is measured in the base currency, profit and loss in the quote currency.
+type Position struct {
+	Symbol     data.Symbol
+	Size       float64 // Positive for long positions, negative for short positions.
+	EntryPrice float64 // Average price at which the position was opened.
+	MarkPrice  float64 // Last price of the symbol.
+	Margin     float64 // Margin allocated to the position in the isolated mode.
+}
+
+// UnrealizedPnL returns the profit or loss of the position at the mark price.
+func (p Position) UnrealizedPnL() float64 {
+	return p.Size * (p.MarkPrice - p.EntryPrice)
+}
+
+// Notional returns the absolute value of the position at the mark price.
+func (p Position) Notional() float64 {
+	return math.Abs(p.Size) * p.MarkPrice
+}
+
+// FuturesOption configures the FuturesSimulator.
+type FuturesOption func(*FuturesSimulator)
+
+// WithLeverage sets the leverage that determines the initial margin
+// of positions: the notional divided by the leverage. Defaults to 1.
+func WithLeverage(leverage float64) FuturesOption {
+	return func(f *FuturesSimulator) {
+		f.leverage = leverage
+	}
+}
+
+// WithMarginMode sets the margin mode, CrossMargin by default.
+func WithMarginMode(mode MarginMode) FuturesOption {
+	return func(f *FuturesSimulator) {
+		f.mode = mode
+	}
+}
+
+// WithMaintenanceMargin sets the maintenance margin ratio of the notional below
+// which positions are liquidated and the liquidation fee charged as a fraction
+// of the liquidated notional.
+func WithMaintenanceMargin(ratio, liquidationFee float64) FuturesOption {
+	return func(f *FuturesSimulator) {
+		f.maintenance = ratio
+		f.liquidationFee = liquidationFee
+	}
+}
+
+// WithFundingRates sets the funding rates of the symbol.
+// Symbols without funding rates are settled without funding.
+func WithFundingRates(symbol data.Symbol, rates *FundingRates) FuturesOption {
+	return func(f *FuturesSimulator) {
+		f.funding[symbol] = rates
+	}
+}
+
+// WithExecution configures the execution of orders, e.g. slippage, fee schedule
+// or participation rate. Margin requirements of WithMargin do not apply to futures.
+func WithExecution(options ...SimulatorOption) FuturesOption {
+	return func(f *FuturesSimulator) {
+		for _, option := range options {
+			option(&f.MarginSimulator)
+		}
+	}
+}
+
+// FuturesSimulator simulates trading of linear perpetual swaps. Orders are
+// executed in the same way as by the MarginSimulator, but instead of exchanging
+// currencies they open, increase, reduce or flip contract positions. Realized
+// profit and loss, fees and funding payments are settled in the collateral
+// held by the portfolio in the quote currencies of the symbols.
+type FuturesSimulator struct {
+	MarginSimulator
+	positions      map[data.Symbol]*Position     // Open positions by symbol.
+	mode           MarginMode                    // Whether the collateral is shared by the positions.
+	leverage       float64                       // Ratio of the notional to the initial margin.
+	maintenance    float64                       // Maintenance margin ratio of the notional.
+	liquidationFee float64                       // Fee charged on the notional of liquidated positions.
+	funding        map[data.Symbol]*FundingRates // Funding rates of the symbols.
+	settled        map[data.Symbol]time.Time     // Moment up to which the funding of a symbol is settled.
+}
+
+// NewFuturesSimulator creates a FuturesSimulator with the collateral held by the
+// portfolio, charging the commission as a fraction of the traded notional.
+func NewFuturesSimulator(portfolio common.Portfolio, commission float64, options ...FuturesOption) *FuturesSimulator {
+	simulator := &FuturesSimulator{
+		MarginSimulator: NewMarginSimulator(portfolio, commission),
+		positions:       make(map[data.Symbol]*Position, internal.DefaultCapacity),
+		mode:            CrossMargin,
+		leverage:        1,
+		maintenance:     DefaultMaintenanceMargin,
+		funding:         make(map[data.Symbol]*FundingRates),
+		settled:         make(map[data.Symbol]time.Time, internal.DefaultCapacity),
+	}
+
+	for _, option := range options {
+		option(simulator)
+	}
+
+	simulator.settlement = simulator.applyFill
+
+	return simulator
+}
+
+// Position returns the position of the symbol. The size of a missing position is zero.
+func (f *FuturesSimulator) Position(symbol data.Symbol) Position {
+	if position, ok := f.positions[symbol]; ok {
+		return *position
+	}
+
+	return Position{Symbol: symbol}
+}
+
+// Positions returns copies of all open positions.
+func (f *FuturesSimulator) Positions() []Position {
+	positions := make([]Position, 0, len(f.positions))
+	for _, position := range f.positions {
+		positions = append(positions, *position)
+	}
+
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i].Symbol.String() < positions[j].Symbol.String()
+	})
+
+	return positions
+}
+
+// PlaceOrder validates that the available margin covers the initial margin
+// of the position increase and places the order.
+func (f *FuturesSimulator) PlaceOrder(order Order) error {
+	if f.groupExecuted(order) {
+		return nil
+	}
+
+	order, err := f.applyRules(order)
+	if err != nil {
+		return err
+	}
+
+	if order.parent == 0 {
+		if err := f.validOrder(order); err != nil {
+			return f.reject(order, fmt.Errorf("error validating order: %w", err))
+		}
+	}
+
+	return f.place(order)
+}
+
+func (f *FuturesSimulator) validOrder(order Order) error {
+	increase := order.amount
+
+	if size := f.Position(order.symbol).Size; size != 0 && (size > 0) != (order.side == Buy) {
+		increase = math.Max(0, order.amount-math.Abs(size))
+	}
+
+	if increase == 0 {
+		return nil
+	}
+
+	required := f.valueInMain(order.symbol.Quote(), increase*order.price/f.leverage)
+	available := f.AvailableMargin()
+
+	if required > available {
+		return errors.NewInsufficientMarginError(required, available)
+	}
+
+	return nil
+}
+
+// AvailableMargin returns the collateral that can be used to open new positions.
+// In the cross mode unrealized profit and loss is taken into account.
+func (f *FuturesSimulator) AvailableMargin() float64 {
+	cash := f.cash()
+	if f.mode == IsolatedMargin {
+		return cash
+	}
+
+	equity, used := cash, 0.0
+
+	for _, position := range f.positions {
+		quote := position.Symbol.Quote()
+		equity += f.valueInMain(quote, position.UnrealizedPnL())
+		used += f.valueInMain(quote, position.Notional()/f.leverage)
+	}
+
+	return equity - used
+}
+
+// cash returns the value of the collateral held by the portfolio in the main currency.
+func (f *FuturesSimulator) cash() float64 {
+	total := 0.0
+	for currency, balance := range f.portfolio.Assets() {
+		total += f.valueInMain(currency, balance)
+	}
+
+	return total
+}
+
+// applyFill opens, increases, reduces or flips the position of the order symbol.
+// Realized profit and loss and the isolated margin are settled in the quote currency.
+func (f *FuturesSimulator) applyFill(order Order) error {
+	symbol := order.symbol
+	quote := symbol.Quote()
+
+	position, ok := f.positions[symbol]
+	if !ok {
+		position = &Position{Symbol: symbol, MarkPrice: order.price}
+		f.positions[symbol] = position
+	}
+
+	delta := order.amount
+	if order.side == Sell {
+		delta = -delta
+	}
+
+	if position.Size != 0 && (position.Size > 0) != (delta > 0) {
+		closed := math.Min(math.Abs(delta), math.Abs(position.Size))
+		direction := math.Copysign(1, position.Size)
+
+		f.portfolio.Increase(quote, closed*direction*(order.price-position.EntryPrice))
+
+		released := position.Margin * closed / math.Abs(position.Size)
+		position.Margin -= released
+		f.portfolio.Increase(quote, released)
+
+		position.Size -= direction * closed
+		delta += direction * closed
+	}
+
+	if delta != 0 {
+		size := position.Size + delta
+
+		if position.Size == 0 {
+			position.EntryPrice = order.price
+		} else {
+			position.EntryPrice = (position.EntryPrice*math.Abs(position.Size) + order.price*math.Abs(delta)) / math.Abs(size)
+		}
+
+		position.Size = size
+
+		if f.mode == IsolatedMargin {
+			margin := math.Abs(delta) * order.price / f.leverage
+			position.Margin += margin
+			f.portfolio.Decrease(quote, margin)
+		}
+	}
+
+	if position.Size == 0 {
+		delete(f.positions, symbol)
+	}
+
+	return nil
+}
+
+// UpdatePrice updates the mark price of the position, settles the funding,
+// executes the orders and liquidates what is left of the positions if it
+// breaches the maintenance margin within the candle.
+func (f *FuturesSimulator) UpdatePrice(candle data.InstrumentCandle) error {
+	f.observe(candle)
+
+	symbol := candle.Symbol()
+	if position, ok := f.positions[symbol]; ok {
+		position.MarkPrice = candle.Close
+	}
+
+	f.settleFunding(symbol, candle.TimeClose, candle.Close)
+
+	err := f.updateLimits(candle)
+
+	if liquidationErr := f.checkLiquidation(candle); liquidationErr != nil {
+		return fmt.Errorf("liquidation failed: %w", liquidationErr)
+	}
+
+	return err
+}
+
+// settleFunding charges or pays the funding of the position for every
+// settlement since the previous candle of the symbol at the given mark price.
+func (f *FuturesSimulator) settleFunding(symbol data.Symbol, moment time.Time, mark float64) {
+	rates, ok := f.funding[symbol]
+	if !ok {
+		return
+	}
+
+	previous, ok := f.settled[symbol]
+	f.settled[symbol] = moment
+
+	position, open := f.positions[symbol]
+	if !ok || !open {
+		return
+	}
+
+	quote := symbol.Quote()
+
+	for _, settlement := range rates.settlements(previous, moment) {
+		payment := position.Size * mark * rates.Rate(settlement)
+
+		f.portfolio.Decrease(quote, payment)
+		f.costs[FundingCost] += f.valueInMain(quote, payment)
+	}
+}
+
+// checkLiquidation checks the maintenance margin at the candle extreme adverse
+// to the position of the symbol. In the cross mode all positions are liquidated
+// if the equity falls below the maintenance margin of all positions, in the
+// isolated mode only the position whose margin is exhausted is liquidated.
+func (f *FuturesSimulator) checkLiquidation(candle data.InstrumentCandle) error {
+	symbol := candle.Symbol()
+
+	position, ok := f.positions[symbol]
+	if !ok {
+		return nil
+	}
+
+	worst := candle.Low
+	if position.Size < 0 {
+		worst = candle.High
+	}
+
+	// Equity and exposure of everything except the position value,
+	// valued in the quote currency of the symbol.
+	rest, restExposure := position.Margin-position.Size*position.EntryPrice, 0.0
+
+	if f.mode == CrossMargin {
+		rate := f.valueInMain(symbol.Quote(), 1)
+		if rate == 0 {
+			return nil
+		}
+
+		equity, exposure := f.cash(), 0.0
+
+		for _, other := range f.positions {
+			if other.Symbol == symbol {
+				continue
+			}
+
+			quote := other.Symbol.Quote()
+			equity += f.valueInMain(quote, other.UnrealizedPnL())
+			exposure += f.valueInMain(quote, other.Notional())
+		}
+
+		rest += equity / rate
+		restExposure = exposure / rate
+	}
+
+	if rest+position.Size*worst >= f.maintenance*(restExposure+math.Abs(position.Size)*worst) {
+		return nil
+	}
+
+	price := breachPrice(position.Size, rest, restExposure, f.maintenance, candle.Candle)
+
+	if f.mode == IsolatedMargin {
+		f.cancelSymbolOrders(symbol)
+
+		return f.closeFuturesPosition(*position, price)
+	}
+
+	if err := f.CancelAllOrders(); err != nil {
+		return err
+	}
+
+	for _, other := range f.Positions() {
+		closePrice := other.MarkPrice
+		if other.Symbol == symbol {
+			closePrice = price
+		}
+
+		if err := f.closeFuturesPosition(other, closePrice); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f *FuturesSimulator) cancelSymbolOrders(symbol data.Symbol) {
+	for _, order := range f.OpenOrders() {
+		if order.symbol == symbol {
+			_ = f.CancelOrder(order.ID())
+		}
+	}
+}
+
+// closeFuturesPosition executes a liquidation order closing the position
+// at the price and charges the liquidation fee in the quote currency.
+func (f *FuturesSimulator) closeFuturesPosition(position Position, price float64) error {
+	quote := position.Symbol.Quote()
+
+	order, err := NewOrder(position.Symbol, Market, orderSideFromBalance(position.Size), price, math.Abs(position.Size))
+	if err != nil {
+		return err
+	}
+
+	fee := f.liquidationFee * order.amount * price
+	f.portfolio.Decrease(quote, fee)
+	f.costs[LiquidationCost] += f.valueInMain(quote, fee)
+
+	if err := f.settle(*order, Taker, quote, fee); err != nil {
+		return err
+	}
+
+	f.report(*order, Filled)
+
+	return nil
+}
+
+// Total returns the equity: the collateral, the isolated margin
+// and the unrealized profit and loss of all positions.
+func (f *FuturesSimulator) Total() (float64, error) {
+	total, err := f.portfolio.TotalByGraph(f.graph)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, position := range f.positions {
+		total += f.valueInMain(position.Symbol.Quote(), position.Margin+position.UnrealizedPnL())
+	}
+
+	return total, nil
+}
+
+// SellAll closes all positions with market orders at the mark prices.
+func (f *FuturesSimulator) SellAll() error {
+	var firstErr error
+
+	for _, position := range f.Positions() {
+		order, _ := NewOrder(
+			position.Symbol,
+			Market,
+			orderSideFromBalance(position.Size),
+			position.MarkPrice,
+			math.Abs(position.Size),
+		)
+
+		if err := f.PlaceOrder(*order); firstErr == nil && err != nil {
+			firstErr = fmt.Errorf("error during placing closing order: %w", err)
+		}
+	}
+
+	return firstErr
+}
+
+func (f *FuturesSimulator) Cleanup() error {
+	if err := f.MarginSimulator.Cleanup(); err != nil {
+		return err
+	}
+
+	f.positions = make(map[data.Symbol]*Position, internal.DefaultCapacity)
+	f.settled = make(map[data.Symbol]time.Time, internal.DefaultCapacity)
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package exchange
+
+// LinkOCO links orders into a one-cancels-other group: as soon as one of
+// them is executed, the rest are cancelled. The ID of the first order is
+// used as the group ID. It returns the linked copies of the orders.
+func LinkOCO(orders ...Order) []Order {
+	linked := make([]Order, len(orders))
+
+	if len(orders) == 0 {
+		return linked
+	}
+
+	group := orders[0].ID()
+
+	for i, order := range orders {
+		order.group = group
+		linked[i] = order
+	}
+
+	return linked
+}
+
+// LinkBracket makes the exit orders children of the entry order. Children stay
+// inactive until the entry order is filled, are cancelled together with it,
+// and form a one-cancels-other group between themselves.
+// It returns the linked copies of the exit orders.
+func LinkBracket(entry Order, exits ...Order) []Order {
+	linked := LinkOCO(exits...)
+
+	for i := range linked {
+		linked[i].parent = entry.ID()
+	}
+
+	return linked
+}
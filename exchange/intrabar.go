@@ -0,0 +1,172 @@
+package exchange
+
+import (
+	"math"
+
+	"github.com/quick-trade/xoney/common/data"
+)
+
+// IntrabarPath defines the assumed sequence of prices within a candle. Pending
+// orders of a symbol are processed in the order in which the path reaches their
+// prices, so that the first reached order gets the candle volume first and only
+// the first reached order of a one-cancels-other group is executed. Orders
+// reached at the same moment are processed in the order they are stored.
+type IntrabarPath string
+
+const (
+	// PathOHLC assumes the price moves from the open to the high, then to the low
+	// and then to the close.
+	PathOHLC IntrabarPath = "ohlc"
+	// PathOLHC assumes the price moves from the open to the low, then to the high
+	// and then to the close.
+	PathOLHC IntrabarPath = "olhc"
+	// PathNearestExtreme assumes the price visits the extreme closer to the open
+	// first. If both extremes are equally distant, the high is visited first.
+	PathNearestExtreme IntrabarPath = "nearest_extreme"
+	// PathPessimistic assumes the worst sequence for the trader: stop and trailing
+	// stop orders are reached as early as any of the OHLC and OLHC paths allows,
+	// limit and take-profit orders are reached as late as possible.
+	PathPessimistic IntrabarPath = "pessimistic"
+)
+
+// WithIntrabarPath sets the intrabar path model, PathNearestExtreme by default.
+func WithIntrabarPath(path IntrabarPath) SimulatorOption {
+	return func(s *MarginSimulator) {
+		s.path = path
+	}
+}
+
+// pricePath is a sequence of prices visited within a candle. Moments on the path
+// are measured in segments: the moment 1.5 is the middle of the second segment.
+type pricePath []float64
+
+func ohlcPath(candle data.Candle) pricePath {
+	return pricePath{candle.Open, candle.High, candle.Low, candle.Close}
+}
+
+func olhcPath(candle data.Candle) pricePath {
+	return pricePath{candle.Open, candle.Low, candle.High, candle.Close}
+}
+
+// paths returns the candidate paths of the candle for the path model.
+func (m IntrabarPath) paths(candle data.Candle) []pricePath {
+	switch m {
+	case PathOHLC:
+		return []pricePath{ohlcPath(candle)}
+	case PathOLHC:
+		return []pricePath{olhcPath(candle)}
+	case PathPessimistic:
+		return []pricePath{ohlcPath(candle), olhcPath(candle)}
+	case PathNearestExtreme:
+	}
+
+	if candle.High-candle.Open <= candle.Open-candle.Low {
+		return []pricePath{ohlcPath(candle)}
+	}
+
+	return []pricePath{olhcPath(candle)}
+}
+
+// reach returns the first moment at which the price reaches the level from below
+// if rising is set or from above otherwise.
+func (p pricePath) reach(level float64, rising bool) (float64, bool) {
+	reached := func(price float64) bool {
+		if rising {
+			return price >= level
+		}
+
+		return price <= level
+	}
+
+	if reached(p[0]) {
+		return 0, true
+	}
+
+	for i := 1; i < len(p); i++ {
+		if reached(p[i]) {
+			previous := p[i-1]
+
+			return float64(i-1) + (level-previous)/(p[i]-previous), true
+		}
+	}
+
+	return 0, false
+}
+
+// at returns the price at the moment.
+func (p pricePath) at(moment float64) float64 {
+	index := int(moment)
+	if index >= len(p)-1 {
+		return p[len(p)-1]
+	}
+
+	share := moment - float64(index)
+
+	return p[index] + share*(p[index+1]-p[index])
+}
+
+// extremes returns the highest and the lowest prices visited after the moment.
+func (p pricePath) extremes(moment float64) (high, low float64) {
+	high = p.at(moment)
+	low = high
+
+	for i := int(moment) + 1; i < len(p); i++ {
+		high = math.Max(high, p[i])
+		low = math.Min(low, p[i])
+	}
+
+	return high, low
+}
+
+// level returns the price at which a pending order is reached
+// and whether it is reached by rising prices.
+func (o Order) level() (float64, bool) {
+	switch o.orderType {
+	case Stop, StopLimit, TrailingStop:
+		return o.stopPrice, o.side == Buy
+	case TakeProfit:
+		return o.stopPrice, o.side == Sell
+	default:
+		return o.price, o.side == Sell
+	}
+}
+
+// pessimistic reports whether the order hurts the trader when it is reached
+// early, so that the pessimistic path reaches it as early as possible.
+func (o Order) pessimistic() bool {
+	switch o.orderType {
+	case Stop, StopLimit, TrailingStop:
+		return true
+	default:
+		return false
+	}
+}
+
+// schedule returns the moment at which the path model reaches the order within
+// the candle and the path on which it happens. Orders that are not reached are
+// scheduled after the end of the candle.
+func (s *MarginSimulator) schedule(order Order, candle data.Candle) (float64, pricePath) {
+	level, rising := order.level()
+	paths := s.path.paths(candle)
+
+	moment, chosen := math.Inf(1), paths[0]
+	found := false
+
+	for _, path := range paths {
+		reached, ok := path.reach(level, rising)
+		if !ok {
+			continue
+		}
+
+		better := reached < moment
+		if found && !order.pessimistic() {
+			better = reached > moment
+		}
+
+		if !found || better {
+			moment, chosen, found = reached, path, true
+		}
+	}
+
+	return moment, chosen
+}
@@ -0,0 +1,249 @@
+package exchange
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/errors"
+	"github.com/quick-trade/xoney/internal"
+)
+
+// marginRules holds the margin requirements of a MarginSimulator.
+// Ratios are fractions of the exposure, that is the absolute value
+// of all positions in the main currency.
+type marginRules struct {
+	initial        float64 // Equity required to open or increase positions.
+	maintenance    float64 // Equity below which the positions are liquidated.
+	liquidationFee float64 // Fee charged on the notional of liquidated positions.
+}
+
+// WithMargin limits borrowing in the MarginSimulator. Orders that increase the
+// exposure are rejected if the equity after the execution is below the initial
+// ratio of the exposure, e.g. 0.1 for the leverage of 10. When the equity falls
+// below the maintenance ratio, all positions are liquidated at the price where
+// the requirement was breached, and the liquidation fee is charged
+// as a fraction of the liquidated notional.
+func WithMargin(initial, maintenance, liquidationFee float64) SimulatorOption {
+	return func(s *MarginSimulator) {
+		s.margin = &marginRules{
+			initial:        initial,
+			maintenance:    maintenance,
+			liquidationFee: liquidationFee,
+		}
+	}
+}
+
+// WithBorrowRates sets annualized interest rates on borrowed currencies, e.g. 0.1
+// for 10% a year. Interest accrues on negative balances with every price update
+// in proportion to the time elapsed since the previous update and is deducted
+// from the borrowed currency. Short positions accrue the rate of their base
+// currency, leveraged long positions accrue the rate of the quote currency.
+func WithBorrowRates(rates map[data.Currency]float64) SimulatorOption {
+	return func(s *MarginSimulator) {
+		s.borrowRates = internal.MapCopy(rates)
+	}
+}
+
+// accrueInterest charges the interest on negative balances
+// for the time elapsed until the moment.
+func (s *MarginSimulator) accrueInterest(moment time.Time) {
+	if s.now.IsZero() || !moment.After(s.now) {
+		return
+	}
+
+	share := float64(moment.Sub(s.now)) / float64(internal.Year)
+
+	for currency, rate := range s.borrowRates {
+		balance := s.portfolio.Balance(currency)
+		if balance >= 0 {
+			continue
+		}
+
+		interest := -balance * rate * share
+
+		s.portfolio.Decrease(currency, interest)
+		s.costs[InterestCost] += s.valueInMain(currency, interest)
+	}
+}
+
+// account returns the equity and the exposure of the balances in the main
+// currency. The currency is valued at the given price instead of the current one.
+func (s *MarginSimulator) account(
+	balances map[data.Currency]float64,
+	currency data.Currency,
+	price float64,
+) (equity, exposure float64) {
+	main := s.portfolio.MainCurrency()
+
+	for asset, balance := range balances {
+		value := s.valueInMain(asset, balance)
+		if asset == currency {
+			value = balance * price
+		}
+
+		equity += value
+
+		if asset != main {
+			exposure += math.Abs(value)
+		}
+	}
+
+	return equity, exposure
+}
+
+// validMargin checks that the equity after the execution of the order at its
+// price meets the initial margin requirement. Orders reducing the exposure
+// are always valid.
+func (s *MarginSimulator) validMargin(order Order) error {
+	if s.margin == nil {
+		return nil
+	}
+
+	base := order.symbol.Base()
+	quote := order.symbol.Quote()
+
+	price := s.prices[base]
+	if price == 0 {
+		price = s.valueInMain(quote, order.price)
+	}
+
+	balances := internal.MapCopy(s.portfolio.Assets())
+	_, exposure := s.account(balances, base, price)
+
+	if order.side == Buy {
+		balances[base] += order.amount
+		balances[quote] -= order.amount * order.price
+	} else {
+		balances[base] -= order.amount
+		balances[quote] += order.amount * order.price
+	}
+
+	equity, after := s.account(balances, base, price)
+	required := s.margin.initial * after
+
+	if after > exposure && equity < required {
+		return errors.NewInsufficientMarginError(required, equity)
+	}
+
+	return nil
+}
+
+// checkLiquidation checks the maintenance margin requirement at the candle
+// extreme adverse to the position in its base currency, and liquidates
+// all positions if the requirement is breached within the candle.
+func (s *MarginSimulator) checkLiquidation(candle data.InstrumentCandle) error {
+	symbol := candle.Symbol()
+	base := symbol.Base()
+	position := s.portfolio.Balance(base)
+
+	if s.margin == nil || position == 0 || symbol.Quote() != s.portfolio.MainCurrency() {
+		return nil
+	}
+
+	worst := candle.Low
+	if position < 0 {
+		worst = candle.High
+	}
+
+	balances := s.portfolio.Assets()
+	equity, exposure := s.account(balances, base, worst)
+
+	if equity >= s.margin.maintenance*exposure {
+		return nil
+	}
+
+	return s.liquidate(symbol, s.liquidationPrice(base, position, candle.Candle))
+}
+
+// liquidationPrice returns the price of the base currency at which the equity
+// reaches the maintenance margin. If the candle opens beyond this price,
+// the positions are liquidated at the open.
+func (s *MarginSimulator) liquidationPrice(base data.Currency, position float64, candle data.Candle) float64 {
+	// Equity and exposure of everything except the position.
+	rest, restExposure := s.account(s.portfolio.Assets(), base, 0)
+
+	return breachPrice(position, rest, restExposure, s.margin.maintenance, candle)
+}
+
+// breachPrice solves rest + position*price = maintenance*(restExposure + |position|*price)
+// for the price and bounds the result by the candle: the price cannot be better
+// than the open and cannot be beyond the adverse extreme of the candle.
+func breachPrice(position, rest, restExposure, maintenance float64, candle data.Candle) float64 {
+	denominator := position - maintenance*math.Abs(position)
+
+	if position > 0 {
+		if denominator <= 0 {
+			return candle.Low
+		}
+
+		price := (maintenance*restExposure - rest) / denominator
+
+		return math.Max(math.Min(price, candle.Open), candle.Low)
+	}
+
+	price := (maintenance*restExposure - rest) / denominator
+
+	return math.Min(math.Max(price, candle.Open), candle.High)
+}
+
+// liquidate cancels all orders and closes all positions against the main
+// currency. The position in the base currency of the symbol is closed at the
+// given price, other positions are closed at their current prices.
+func (s *MarginSimulator) liquidate(symbol data.Symbol, price float64) error {
+	if err := s.CancelAllOrders(); err != nil {
+		return err
+	}
+
+	main := s.portfolio.MainCurrency()
+	currencies := make([]data.Currency, 0, len(s.prices))
+
+	for currency := range s.prices {
+		if currency != main && s.portfolio.Balance(currency) != 0 {
+			currencies = append(currencies, currency)
+		}
+	}
+
+	sort.Slice(currencies, func(i, j int) bool {
+		return currencies[i].String() < currencies[j].String()
+	})
+
+	for _, currency := range currencies {
+		closePrice := s.prices[currency]
+		if currency == symbol.Base() {
+			closePrice = price
+		}
+
+		if err := s.closePosition(currency, closePrice); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// closePosition executes a liquidation order closing the position in the
+// currency and charges the liquidation fee in the main currency.
+func (s *MarginSimulator) closePosition(currency data.Currency, price float64) error {
+	main := s.portfolio.MainCurrency()
+	balance := s.portfolio.Balance(currency)
+	symbol := data.NewSymbolFromCurrencies(currency, main)
+
+	order, err := NewOrder(*symbol, Market, orderSideFromBalance(balance), price, math.Abs(balance))
+	if err != nil {
+		return err
+	}
+
+	fee := s.margin.liquidationFee * order.amount * price
+	s.portfolio.Decrease(main, fee)
+	s.costs[LiquidationCost] += fee
+
+	if err := s.settle(*order, Taker, main, fee); err != nil {
+		return err
+	}
+
+	s.report(*order, Filled)
+
+	return nil
+}
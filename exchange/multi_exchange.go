@@ -0,0 +1,485 @@
+package exchange
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/quick-trade/xoney/common"
+	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/errors"
+	"github.com/quick-trade/xoney/internal"
+)
+
+// TransferCost is the cost of withdrawal fees of transfers between exchanges.
+const TransferCost CostKind = "transfer"
+
+// TransferRule describes withdrawals of a currency from an exchange.
+type TransferRule struct {
+	Fee   float64       // Fixed withdrawal fee in the transferred currency.
+	Delay time.Duration // Time until the funds are credited on the target exchange.
+}
+
+// PendingTransfer is a transfer of funds between exchanges that has not landed yet.
+type PendingTransfer struct {
+	Currency data.Currency // Transferred currency on the source exchange.
+	Target   data.Exchange
+	Quantity float64   // Quantity credited on the target exchange, net of the fee.
+	Arrival  time.Time // Moment when the funds are credited.
+}
+
+// wallet is implemented by the simulators of this package, which
+// allow funds to be withdrawn and deposited by a MultiExchangeSimulator.
+type wallet interface {
+	Simulator
+	withdraw(currency data.Currency, quantity float64) error
+	deposit(currency data.Currency, quantity float64)
+}
+
+func (s *MarginSimulator) withdraw(currency data.Currency, quantity float64) error {
+	if s.portfolio.Balance(currency) < quantity {
+		return errors.NewNotEnoughFundsError(currency.String(), quantity)
+	}
+
+	s.portfolio.Decrease(currency, quantity)
+
+	return nil
+}
+
+func (s *MarginSimulator) deposit(currency data.Currency, quantity float64) {
+	s.portfolio.Increase(currency, quantity)
+}
+
+// MultiExchangeOption configures a MultiExchangeSimulator.
+type MultiExchangeOption func(*MultiExchangeSimulator)
+
+// WithTransferRule sets the withdrawal fee and the delay of transfers of the
+// currency from its exchange. Transfers without a rule are free and instant.
+func WithTransferRule(currency data.Currency, rule TransferRule) MultiExchangeOption {
+	return func(m *MultiExchangeSimulator) {
+		m.rules[currency] = rule
+	}
+}
+
+// MultiExchangeSimulator combines simulators of several exchanges. Orders,
+// candles and price requests are routed to the simulator of the exchange of
+// their symbol. Transfers between exchanges are charged withdrawal fees and
+// credited on the target exchange after a delay; the funds in flight are not
+// available on any exchange but are included in the total balance.
+//
+// The main currencies of the simulators are expected to be the same asset,
+// e.g. USDT on every exchange.
+type MultiExchangeSimulator struct {
+	venues    map[data.Exchange]wallet
+	exchanges []data.Exchange // Exchanges of the simulators in sorted order.
+	rules     map[data.Currency]TransferRule
+	transfers []PendingTransfer         // Transfers in flight ordered by arrival.
+	routes    map[OrderID]data.Exchange // Exchange of every placed order.
+	costs     Costs                     // Withdrawal fees in the main currency.
+	now       time.Time                 // Close time of the last processed candle.
+}
+
+// NewMultiExchangeSimulator creates a MultiExchangeSimulator from the simulators
+// of the exchanges. The simulators must be created by this package: MarginSimulator,
+// SpotSimulator, FuturesSimulator or OrderBookSimulator.
+func NewMultiExchangeSimulator(
+	simulators map[data.Exchange]Simulator,
+	options ...MultiExchangeOption,
+) (*MultiExchangeSimulator, error) {
+	simulator := &MultiExchangeSimulator{
+		venues:    make(map[data.Exchange]wallet, len(simulators)),
+		exchanges: make([]data.Exchange, 0, len(simulators)),
+		rules:     make(map[data.Currency]TransferRule),
+		transfers: make([]PendingTransfer, 0, internal.DefaultCapacity),
+		routes:    make(map[OrderID]data.Exchange, internal.DefaultCapacity),
+		costs:     make(Costs),
+	}
+
+	for exchange, venue := range simulators {
+		account, ok := venue.(wallet)
+		if !ok {
+			return nil, fmt.Errorf("simulator of %v does not support transfers", exchange)
+		}
+
+		simulator.venues[exchange] = account
+		simulator.exchanges = append(simulator.exchanges, exchange)
+	}
+
+	sort.Slice(simulator.exchanges, func(i, j int) bool {
+		return simulator.exchanges[i] < simulator.exchanges[j]
+	})
+
+	for _, option := range options {
+		option(simulator)
+	}
+
+	return simulator, nil
+}
+
+// Simulator returns the simulator of the exchange.
+func (m *MultiExchangeSimulator) Simulator(exchange data.Exchange) (Simulator, bool) {
+	venue, ok := m.venues[exchange]
+
+	return venue, ok
+}
+
+func (m *MultiExchangeSimulator) venue(exchange data.Exchange) (wallet, error) {
+	venue, ok := m.venues[exchange]
+	if !ok {
+		return nil, errors.NewUnknownExchangeError(string(exchange))
+	}
+
+	return venue, nil
+}
+
+// PlaceOrder places the order on the exchange of its symbol.
+func (m *MultiExchangeSimulator) PlaceOrder(order Order) error {
+	venue, err := m.venue(order.symbol.Exchange())
+	if err != nil {
+		return err
+	}
+
+	m.routes[order.ID()] = order.symbol.Exchange()
+
+	return venue.PlaceOrder(order)
+}
+
+func (m *MultiExchangeSimulator) CancelOrder(id OrderID) error {
+	exchange, ok := m.routes[id]
+	if !ok {
+		return errors.NewUnknownOrderError(uint64(id))
+	}
+
+	return m.venues[exchange].CancelOrder(id)
+}
+
+func (m *MultiExchangeSimulator) CancelAllOrders() error {
+	for _, exchange := range m.exchanges {
+		if err := m.venues[exchange].CancelAllOrders(); err != nil {
+			return fmt.Errorf("error cancelling orders on %v: %w", exchange, err)
+		}
+	}
+
+	return nil
+}
+
+// OpenOrders returns the open orders of all exchanges.
+func (m *MultiExchangeSimulator) OpenOrders() []Order {
+	orders := make([]Order, 0, internal.DefaultCapacity)
+
+	for _, exchange := range m.exchanges {
+		orders = append(orders, m.venues[exchange].OpenOrders()...)
+	}
+
+	return orders
+}
+
+func (m *MultiExchangeSimulator) OrderStatus(id OrderID) (OrderStatus, error) {
+	exchange, ok := m.routes[id]
+	if !ok {
+		return "", errors.NewUnknownOrderError(uint64(id))
+	}
+
+	return m.venues[exchange].OrderStatus(id)
+}
+
+// FillStream returns the executions on all exchanges that occurred
+// since the previous call. The returned channel is closed.
+func (m *MultiExchangeSimulator) FillStream() <-chan Fill {
+	fills := make([]Fill, 0, internal.DefaultCapacity)
+	for _, exchange := range m.exchanges {
+		for fill := range m.venues[exchange].FillStream() {
+			fills = append(fills, fill)
+		}
+	}
+
+	sort.SliceStable(fills, func(i, j int) bool {
+		return fills[i].Time.Before(fills[j].Time)
+	})
+
+	stream := make(chan Fill, len(fills))
+	defer close(stream)
+
+	for _, fill := range fills {
+		stream <- fill
+	}
+
+	return stream
+}
+
+// OrderUpdates returns the order updates on all exchanges that occurred
+// since the previous call. The returned channel is closed.
+func (m *MultiExchangeSimulator) OrderUpdates() <-chan OrderUpdate {
+	updates := make([]OrderUpdate, 0, internal.DefaultCapacity)
+	for _, exchange := range m.exchanges {
+		for update := range m.venues[exchange].OrderUpdates() {
+			updates = append(updates, update)
+		}
+	}
+
+	sort.SliceStable(updates, func(i, j int) bool {
+		return updates[i].Time.Before(updates[j].Time)
+	})
+
+	stream := make(chan OrderUpdate, len(updates))
+	defer close(stream)
+
+	for _, update := range updates {
+		stream <- update
+	}
+
+	return stream
+}
+
+// Fills returns the executions on all exchanges ordered by time.
+func (m *MultiExchangeSimulator) Fills() []Fill {
+	fills := make([]Fill, 0, internal.DefaultCapacity)
+	for _, exchange := range m.exchanges {
+		fills = append(fills, m.venues[exchange].Fills()...)
+	}
+
+	sort.SliceStable(fills, func(i, j int) bool {
+		return fills[i].Time.Before(fills[j].Time)
+	})
+
+	return fills
+}
+
+// Transfer withdraws the quantity of the currency from its exchange. The quantity
+// net of the withdrawal fee is credited on the target exchange after the delay
+// set by the transfer rule of the currency.
+func (m *MultiExchangeSimulator) Transfer(quantity float64, currency data.Currency, target data.Exchange) error {
+	source, err := m.venue(currency.Exchange)
+	if err != nil {
+		return err
+	}
+
+	if _, err := m.venue(target); err != nil {
+		return err
+	}
+
+	rule := m.rules[currency]
+	if quantity <= rule.Fee {
+		return errors.NewTransferFeeError(quantity, rule.Fee)
+	}
+
+	fee := 0.0
+	if rule.Fee != 0 {
+		if fee, err = valueIn(source, currency, rule.Fee); err != nil {
+			return fmt.Errorf("error valuing withdrawal fee: %w", err)
+		}
+	}
+
+	if err := source.withdraw(currency, quantity); err != nil {
+		return err
+	}
+
+	if fee != 0 {
+		m.costs[TransferCost] += fee
+	}
+
+	transfer := PendingTransfer{
+		Currency: currency,
+		Target:   target,
+		Quantity: quantity - rule.Fee,
+		Arrival:  m.now.Add(rule.Delay),
+	}
+
+	index := sort.Search(len(m.transfers), func(i int) bool {
+		return m.transfers[i].Arrival.After(transfer.Arrival)
+	})
+
+	m.transfers = append(m.transfers, PendingTransfer{})
+	copy(m.transfers[index+1:], m.transfers[index:])
+	m.transfers[index] = transfer
+
+	m.land(m.now)
+
+	return nil
+}
+
+// InFlight returns the transfers that have not landed yet.
+func (m *MultiExchangeSimulator) InFlight() []PendingTransfer {
+	return append([]PendingTransfer(nil), m.transfers...)
+}
+
+// land credits the transfers that have arrived by the moment.
+func (m *MultiExchangeSimulator) land(moment time.Time) {
+	count := 0
+
+	for _, transfer := range m.transfers {
+		if transfer.Arrival.After(moment) {
+			break
+		}
+
+		currency := transfer.Currency
+		currency.Exchange = transfer.Target
+
+		m.venues[transfer.Target].deposit(currency, transfer.Quantity)
+		count++
+	}
+
+	m.transfers = m.transfers[count:]
+}
+
+// UpdatePrice passes the candle to the simulator of the exchange
+// of its symbol and credits the transfers landed by its close.
+func (m *MultiExchangeSimulator) UpdatePrice(candle data.InstrumentCandle) error {
+	venue, err := m.venue(candle.Symbol().Exchange())
+	if err != nil {
+		return err
+	}
+
+	if candle.TimeClose.After(m.now) {
+		m.now = candle.TimeClose
+	}
+
+	if err := venue.UpdatePrice(candle); err != nil {
+		return err
+	}
+
+	m.land(m.now)
+
+	return nil
+}
+
+// Portfolio returns the balances on all exchanges. The funds in flight are not included.
+func (m *MultiExchangeSimulator) Portfolio() common.Portfolio {
+	var portfolio common.Portfolio
+
+	for index, exchange := range m.exchanges {
+		venuePortfolio := m.venues[exchange].Portfolio()
+		if index == 0 {
+			portfolio = common.NewPortfolio(venuePortfolio.MainCurrency())
+		}
+
+		for currency, quantity := range venuePortfolio.Assets() {
+			portfolio.Increase(currency, quantity)
+		}
+	}
+
+	return portfolio
+}
+
+// Total returns the sum of the balances on all exchanges
+// and the value of the funds in flight.
+func (m *MultiExchangeSimulator) Total() (float64, error) {
+	total := 0.0
+
+	for _, exchange := range m.exchanges {
+		venueTotal, err := m.venues[exchange].Total()
+		if err != nil {
+			return total, fmt.Errorf("error getting total balance on %v: %w", exchange, err)
+		}
+
+		total += venueTotal
+	}
+
+	for _, transfer := range m.transfers {
+		value, err := valueIn(m.venues[transfer.Currency.Exchange], transfer.Currency, transfer.Quantity)
+		if err != nil {
+			return total, fmt.Errorf("error valuing transfer in flight: %w", err)
+		}
+
+		total += value
+	}
+
+	return total, nil
+}
+
+// Costs returns the trading costs on all exchanges and the withdrawal fees.
+func (m *MultiExchangeSimulator) Costs() Costs {
+	costs := internal.MapCopy(m.costs)
+
+	for _, exchange := range m.exchanges {
+		reporter, ok := m.venues[exchange].(interface{ Costs() Costs })
+		if !ok {
+			continue
+		}
+
+		for kind, cost := range reporter.Costs() {
+			costs[kind] += cost
+		}
+	}
+
+	return costs
+}
+
+func (m *MultiExchangeSimulator) SellAll() error {
+	var firstErr error
+
+	for _, exchange := range m.exchanges {
+		if err := m.venues[exchange].SellAll(); firstErr == nil && err != nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// GetPrices returns the prices of the symbols on their exchanges. Both returned channels are closed.
+func (m *MultiExchangeSimulator) GetPrices(symbols []data.Symbol) (<-chan SymbolPrice, <-chan error) {
+	prices := make(chan SymbolPrice, len(symbols))
+	defer close(prices)
+
+	err := make(chan error, 1)
+	defer close(err)
+
+	for _, symbol := range symbols {
+		venue, venueErr := m.venue(symbol.Exchange())
+		if venueErr != nil {
+			err <- venueErr
+
+			return prices, err
+		}
+
+		venuePrices, venueErrs := venue.GetPrices([]data.Symbol{symbol})
+		for price := range venuePrices {
+			prices <- price
+		}
+
+		if priceErr := <-venueErrs; priceErr != nil {
+			err <- priceErr
+
+			return prices, err
+		}
+	}
+
+	return prices, err
+}
+
+// Cleanup resets the simulators of all exchanges and drops the transfers in flight.
+func (m *MultiExchangeSimulator) Cleanup() error {
+	for _, exchange := range m.exchanges {
+		if err := m.venues[exchange].Cleanup(); err != nil {
+			return fmt.Errorf("error cleaning up %v: %w", exchange, err)
+		}
+	}
+
+	m.transfers = m.transfers[:0]
+	m.routes = make(map[OrderID]data.Exchange, internal.DefaultCapacity)
+	m.costs = make(Costs)
+	m.now = time.Time{}
+
+	return nil
+}
+
+// valueIn returns the value of the quantity of the currency
+// in the main currency of the simulator.
+func valueIn(simulator Simulator, currency data.Currency, quantity float64) (float64, error) {
+	main := simulator.Portfolio().MainCurrency()
+	if currency.Asset == main.Asset {
+		return quantity, nil
+	}
+
+	symbol := data.NewSymbolFromCurrencies(currency, main)
+
+	prices, errs := simulator.GetPrices([]data.Symbol{*symbol})
+	if err := <-errs; err != nil {
+		return 0, err
+	}
+
+	price := <-prices
+
+	return quantity * price.Price, nil
+}
@@ -1,16 +1,23 @@
 package exchange
 
 import (
+	"time"
+
+	"github.com/quick-trade/xoney/common"
 	"github.com/quick-trade/xoney/common/data"
 	"github.com/quick-trade/xoney/errors"
-	"github.com/quick-trade/xoney/internal"
 )
 
 type OrderType string
 
 const (
-	Market OrderType = "market"
-	Limit  OrderType = "limit"
+	Market     OrderType = "market"
+	Limit      OrderType = "limit"
+	Stop       OrderType = "stop"        // Market order activated when the stop price is reached.
+	StopLimit  OrderType = "stop_limit"  // Limit order activated when the stop price is reached.
+	TakeProfit OrderType = "take_profit" // Market order activated when the target price is reached.
+	// TrailingStop is a stop order whose stop price follows the best price seen since placement.
+	TrailingStop OrderType = "trailing_stop"
 )
 
 type OrderSide string
@@ -20,6 +27,16 @@ const (
 	Sell OrderSide = "sell"
 )
 
+// TimeInForce defines how long an order stays active before it is executed or expired.
+type TimeInForce string
+
+const (
+	GTC TimeInForce = "GTC" // Good-til-cancelled: the order is active until executed or cancelled.
+	IOC TimeInForce = "IOC" // Immediate-or-cancel: the order is filled as much as possible at once, the rest expires.
+	FOK TimeInForce = "FOK" // Fill-or-kill: the order is either filled entirely at once or expires.
+	GTD TimeInForce = "GTD" // Good-til-date: the order is active until its expiration time.
+)
+
 type OrderID uint64
 
 type Order struct {
@@ -28,7 +45,21 @@ type Order struct {
 	side       OrderSide
 	internalID OrderID
 	price      float64
+	stopPrice  float64
 	amount     float64
+	filled     float64
+	trailing   trailing
+	group      OrderID // ID shared by one-cancels-other orders, zero if the order is not linked.
+	parent     OrderID // ID of the order that has to be filled before this one becomes active.
+	tif        TimeInForce
+	expiration time.Time // Expiration moment of a GTD order.
+}
+
+// trailing holds the state of a TrailingStop order.
+type trailing struct {
+	offset    float64 // Distance between the best price and the stop price.
+	percent   bool    // Whether the offset is a fraction of the best price.
+	bestPrice float64 // Best price seen since the order was placed.
 }
 
 func (o Order) Symbol() data.Symbol { return o.symbol }
@@ -38,6 +69,64 @@ func (o Order) ID() OrderID         { return o.internalID }
 func (o Order) Price() float64      { return o.price }
 func (o Order) Amount() float64     { return o.amount }
 
+// Filled returns the amount of the order that has already been executed.
+func (o Order) Filled() float64 { return o.filled }
+
+// Remaining returns the amount of the order that has not been executed yet.
+func (o Order) Remaining() float64 { return o.amount - o.filled }
+
+// Group returns the ID of the one-cancels-other group of the order.
+// Orders of the same group are cancelled as soon as one of them is executed.
+// Zero means that the order does not belong to any group.
+func (o Order) Group() OrderID { return o.group }
+
+// Parent returns the ID of the order that activates this one when filled.
+// Zero means that the order is active as soon as it is placed.
+func (o Order) Parent() OrderID { return o.parent }
+
+func (o Order) TimeInForce() TimeInForce { return o.tif }
+
+// Expiration returns the moment when a GTD order expires.
+func (o Order) Expiration() time.Time { return o.expiration }
+
+// SetTimeInForce sets the lifetime of the order. Use ExpireAt for GTD orders.
+func (o *Order) SetTimeInForce(tif TimeInForce) {
+	o.tif = tif
+}
+
+// ExpireAt makes the order a GTD order expiring at the given moment.
+func (o *Order) ExpireAt(moment time.Time) {
+	o.tif = GTD
+	o.expiration = moment
+}
+
+// SetPrice changes the price of the order. Simulators execute market orders
+// at their price, so it is used to reprice market orders that reach the
+// exchange later than they were created.
+func (o *Order) SetPrice(price float64) {
+	o.price = price
+}
+
+// immediate reports whether the order must expire if it is not filled
+// within the first candle it is checked against.
+func (o Order) immediate() bool {
+	return o.tif == IOC || o.tif == FOK
+}
+
+// expiredAt reports whether a GTD order has expired by the given moment.
+// A GTD order with zero expiration never expires.
+func (o Order) expiredAt(moment time.Time) bool {
+	return o.tif == GTD && !o.expiration.IsZero() && !moment.Before(o.expiration)
+}
+
+// StopPrice returns the trigger price of a conditional order.
+// For Stop and TakeProfit orders it is equal to Price.
+func (o Order) StopPrice() float64 { return o.stopPrice }
+
+// TrailingOffset returns the distance kept by a TrailingStop order between the best
+// price and the stop price. It is a fraction of the best price for percentage offsets.
+func (o Order) TrailingOffset() float64 { return o.trailing.offset }
+
 func (o Order) IsEqual(other *Order) bool {
 	if other.amount != o.amount {
 		return false
@@ -59,6 +148,10 @@ func (o Order) IsEqual(other *Order) bool {
 		return false
 	}
 
+	if other.stopPrice != o.stopPrice {
+		return false
+	}
+
 	return true
 }
 
@@ -70,7 +163,160 @@ func (o Order) CrossesPrice(high, low float64) bool {
 	return high >= o.price
 }
 
+// IsConditional reports whether the order waits for its stop price
+// to be reached before it is executed.
+func (o Order) IsConditional() bool {
+	switch o.orderType {
+	case Stop, StopLimit, TakeProfit, TrailingStop:
+		return true
+	default:
+		return false
+	}
+}
+
+// Triggered reports whether a price range reaches the stop price of a conditional order.
+// Stop orders are triggered by an adverse move (a buy stop by rising prices),
+// take-profit orders are triggered by a favourable one.
+func (o Order) Triggered(high, low float64) bool {
+	switch o.orderType {
+	case Stop, StopLimit, TrailingStop:
+		if o.side == Buy {
+			return high >= o.stopPrice
+		}
+
+		return low <= o.stopPrice
+	case TakeProfit:
+		if o.side == Buy {
+			return low <= o.stopPrice
+		}
+
+		return high >= o.stopPrice
+	default:
+		return false
+	}
+}
+
+// triggerPrice returns the price at which a triggered order is executed.
+// If the candle opens beyond the stop price, the order is filled at the open price.
+func (o Order) triggerPrice(open float64) float64 {
+	if o.Triggered(open, open) {
+		return open
+	}
+
+	return o.stopPrice
+}
+
+// trail moves the stop price of a TrailingStop order after the market
+// reaches a new best price within the given range. The stop price never
+// moves against the position.
+func (o *Order) trail(high, low float64) {
+	best := o.trailing.bestPrice
+
+	switch {
+	case o.side == Sell && high > best:
+		o.trailing.bestPrice = high
+	case o.side == Buy && low < best:
+		o.trailing.bestPrice = low
+	default:
+		return
+	}
+
+	o.stopPrice = o.trailing.stopPrice(o.side)
+}
+
+// stopPrice calculates the stop price for the current best price.
+func (t trailing) stopPrice(side OrderSide) float64 {
+	distance := t.offset
+	if t.percent {
+		distance *= t.bestPrice
+	}
+
+	if side == Buy {
+		return t.bestPrice + distance
+	}
+
+	return t.bestPrice - distance
+}
+
+// withPrice returns a copy of the order with the execution price replaced.
+func (o Order) withPrice(price float64) Order {
+	o.price = price
+
+	return o
+}
+
+// withAmount returns a copy of the order with the executed amount replaced.
+func (o Order) withAmount(amount float64) Order {
+	o.amount = amount
+
+	return o
+}
+
+// activate converts a triggered StopLimit order into a regular limit order.
+func (o *Order) activate() {
+	o.orderType = Limit
+}
+
+// NewOrder creates an order of any type except TrailingStop, which requires the
+// trailing offset of NewTrailingStopOrder or NewTrailingStopPercentOrder.
+// For conditional orders (Stop, StopLimit and TakeProfit) the price is used as the
+// stop price as well; use NewStopLimitOrder to set different stop and limit prices.
 func NewOrder(symbol data.Symbol, orderType OrderType, side OrderSide, price, amount float64) (*Order, error) {
+	if orderType == TrailingStop {
+		return nil, errors.NewOrderConstructorError(string(orderType), "NewTrailingStopOrder")
+	}
+
+	return newOrder(symbol, orderType, side, price, price, amount)
+}
+
+// NewStopLimitOrder creates a StopLimit order which is placed as a limit order
+// at limitPrice once the market reaches stopPrice.
+func NewStopLimitOrder(symbol data.Symbol, side OrderSide, stopPrice, limitPrice, amount float64) (*Order, error) {
+	return newOrder(symbol, StopLimit, side, limitPrice, stopPrice, amount)
+}
+
+// NewTrailingStopOrder creates a TrailingStop order that keeps its stop price at a fixed
+// distance from the best price seen since placement. referencePrice is the market price
+// at the moment of placement and is used as the initial best price.
+func NewTrailingStopOrder(symbol data.Symbol, side OrderSide, referencePrice, distance, amount float64) (*Order, error) {
+	return newTrailingStopOrder(symbol, side, referencePrice, trailing{offset: distance}, amount)
+}
+
+// NewTrailingStopPercentOrder creates a TrailingStop order that keeps its stop price at
+// a fraction of the best price seen since placement, e.g. 0.05 for a 5% trailing stop.
+func NewTrailingStopPercentOrder(symbol data.Symbol, side OrderSide, referencePrice, fraction, amount float64) (*Order, error) {
+	if fraction >= 1 {
+		return nil, errors.NewInvalidTrailingOffsetError(fraction)
+	}
+
+	return newTrailingStopOrder(symbol, side, referencePrice, trailing{offset: fraction, percent: true}, amount)
+}
+
+func newTrailingStopOrder(
+	symbol data.Symbol,
+	side OrderSide,
+	referencePrice float64,
+	trail trailing,
+	amount float64,
+) (*Order, error) {
+	if trail.offset <= 0 {
+		return nil, errors.NewInvalidTrailingOffsetError(trail.offset)
+	}
+
+	trail.bestPrice = referencePrice
+	stopPrice := trail.stopPrice(side)
+
+	order, err := newOrder(symbol, TrailingStop, side, stopPrice, stopPrice, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	order.trailing = trail
+
+	return order, nil
+}
+
+func newOrder(symbol data.Symbol, orderType OrderType, side OrderSide, price, stopPrice, amount float64) (*Order, error) {
 	if amount <= 0 {
 		return nil, errors.NewInvalidOrderAmountError(amount)
 	}
@@ -83,7 +329,9 @@ func NewOrder(symbol data.Symbol, orderType OrderType, side OrderSide, price, am
 		orderType:  orderType,
 		side:       side,
 		price:      price,
+		stopPrice:  stopPrice,
 		amount:     amount,
-		internalID: OrderID(internal.RandomUint64()),
+		tif:        GTC,
+		internalID: OrderID(common.NextID()),
 	}, nil
 }
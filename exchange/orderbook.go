@@ -0,0 +1,275 @@
+package exchange
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/quick-trade/xoney/internal"
+)
+
+// maxBookLine is the maximum length of a line with a book update.
+const maxBookLine = 16 * 1024 * 1024
+
+// BookLevel is a price level of an order book.
+type BookLevel struct {
+	Price  float64
+	Amount float64
+}
+
+// BookUpdate is a recorded change of an order book. A snapshot replaces the
+// whole book, a diff sets the amounts of the listed levels; levels with zero
+// amount are removed.
+type BookUpdate struct {
+	Time     time.Time
+	Snapshot bool
+	Bids     []BookLevel
+	Asks     []BookLevel
+}
+
+// bookLine is the JSON representation of a BookUpdate:
+//
+//	{"time":"2024-01-01T00:00:00Z","type":"snapshot","bids":[[50000,1.5]],"asks":[[50010,2]]}
+type bookLine struct {
+	Time time.Time    `json:"time"`
+	Type string       `json:"type"`
+	Bids [][2]float64 `json:"bids"`
+	Asks [][2]float64 `json:"asks"`
+}
+
+func levelsFromPairs(pairs [][2]float64) []BookLevel {
+	levels := make([]BookLevel, len(pairs))
+	for i, pair := range pairs {
+		levels[i] = BookLevel{Price: pair[0], Amount: pair[1]}
+	}
+
+	return levels
+}
+
+// ReadBookUpdates reads order book updates stored as JSON lines. Every line
+// holds the time in RFC 3339 format, the type ("snapshot" or "diff", snapshot
+// if omitted) and the bid and ask levels as [price, amount] pairs.
+// The updates are returned sorted by time.
+func ReadBookUpdates(reader io.Reader) ([]BookUpdate, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxBookLine)
+
+	updates := make([]BookUpdate, 0, internal.DefaultCapacity)
+
+	for number := 1; scanner.Scan(); number++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var line bookLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("error parsing book update at line %d: %w", number, err)
+		}
+
+		if line.Type != "" && line.Type != "snapshot" && line.Type != "diff" {
+			return nil, fmt.Errorf("unknown book update type at line %d: %q", number, line.Type)
+		}
+
+		updates = internal.Append(updates, BookUpdate{
+			Time:     line.Time,
+			Snapshot: line.Type != "diff",
+			Bids:     levelsFromPairs(line.Bids),
+			Asks:     levelsFromPairs(line.Asks),
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading book updates: %w", err)
+	}
+
+	sort.SliceStable(updates, func(i, j int) bool {
+		return updates[i].Time.Before(updates[j].Time)
+	})
+
+	return updates, nil
+}
+
+// OrderBook holds the levels of an order book: bids sorted
+// from the best (highest) price, asks from the best (lowest) price.
+type OrderBook struct {
+	bids []BookLevel
+	asks []BookLevel
+}
+
+// NewOrderBook creates an empty OrderBook.
+func NewOrderBook() *OrderBook {
+	return &OrderBook{
+		bids: make([]BookLevel, 0, internal.DefaultCapacity),
+		asks: make([]BookLevel, 0, internal.DefaultCapacity),
+	}
+}
+
+// Bids returns a copy of the bid levels.
+func (o *OrderBook) Bids() []BookLevel { return append([]BookLevel(nil), o.bids...) }
+
+// Asks returns a copy of the ask levels.
+func (o *OrderBook) Asks() []BookLevel { return append([]BookLevel(nil), o.asks...) }
+
+// BestBid returns the highest bid level.
+func (o *OrderBook) BestBid() (BookLevel, bool) { return best(o.bids) }
+
+// BestAsk returns the lowest ask level.
+func (o *OrderBook) BestAsk() (BookLevel, bool) { return best(o.asks) }
+
+func best(levels []BookLevel) (BookLevel, bool) {
+	if len(levels) == 0 {
+		return BookLevel{}, false
+	}
+
+	return levels[0], true
+}
+
+// Mid returns the average of the best bid and ask prices.
+func (o *OrderBook) Mid() (float64, bool) {
+	bid, hasBid := o.BestBid()
+	ask, hasAsk := o.BestAsk()
+
+	if !hasBid || !hasAsk {
+		return 0, false
+	}
+
+	return (bid.Price + ask.Price) / 2, true
+}
+
+// Apply applies a snapshot or a diff to the book.
+func (o *OrderBook) Apply(update BookUpdate) {
+	if update.Snapshot {
+		o.bids = o.bids[:0]
+		o.asks = o.asks[:0]
+	}
+
+	for _, level := range update.Bids {
+		o.bids = setLevel(o.bids, level, Buy)
+	}
+
+	for _, level := range update.Asks {
+		o.asks = setLevel(o.asks, level, Sell)
+	}
+}
+
+// setLevel sets the amount of the level keeping the order of the side.
+func setLevel(levels []BookLevel, level BookLevel, side OrderSide) []BookLevel {
+	index := levelIndex(levels, level.Price, side)
+
+	if index < len(levels) && levels[index].Price == level.Price {
+		if level.Amount <= 0 {
+			return append(levels[:index], levels[index+1:]...)
+		}
+
+		levels[index].Amount = level.Amount
+
+		return levels
+	}
+
+	if level.Amount <= 0 {
+		return levels
+	}
+
+	levels = append(levels, BookLevel{})
+	copy(levels[index+1:], levels[index:])
+	levels[index] = level
+
+	return levels
+}
+
+// levelIndex returns the position of the price on the side of the book.
+func levelIndex(levels []BookLevel, price float64, side OrderSide) int {
+	return sort.Search(len(levels), func(i int) bool {
+		if side == Buy {
+			return levels[i].Price <= price
+		}
+
+		return levels[i].Price >= price
+	})
+}
+
+// side returns the levels of the side on which orders of the given side rest.
+func (o *OrderBook) side(side OrderSide) *[]BookLevel {
+	if side == Buy {
+		return &o.bids
+	}
+
+	return &o.asks
+}
+
+// opposite returns the levels with which orders of the given side are matched.
+func (o *OrderBook) opposite(side OrderSide) *[]BookLevel {
+	if side == Buy {
+		return &o.asks
+	}
+
+	return &o.bids
+}
+
+// amount returns the amount resting at the price on the side.
+func (o *OrderBook) amount(side OrderSide, price float64) float64 {
+	levels := *o.side(side)
+
+	index := levelIndex(levels, price, side)
+	if index < len(levels) && levels[index].Price == price {
+		return levels[index].Amount
+	}
+
+	return 0
+}
+
+// acceptable reports whether an order of the side with the limit price
+// can be matched with the level.
+func acceptable(side OrderSide, limit, price float64) bool {
+	if side == Buy {
+		return price <= limit
+	}
+
+	return price >= limit
+}
+
+// liquidity returns the amount that an order of the side
+// can take from the book up to the limit price.
+func (o *OrderBook) liquidity(side OrderSide, limit float64) float64 {
+	total := 0.0
+
+	for _, level := range *o.opposite(side) {
+		if !acceptable(side, limit, level.Price) {
+			break
+		}
+
+		total += level.Amount
+	}
+
+	return total
+}
+
+// take removes up to the amount from the opposite side of the book up to
+// the limit price and returns the matched parts by level.
+func (o *OrderBook) take(side OrderSide, limit, amount float64) []BookLevel {
+	levels := o.opposite(side)
+	matched := make([]BookLevel, 0)
+
+	for amount > 0 && len(*levels) != 0 {
+		level := &(*levels)[0]
+		if !acceptable(side, limit, level.Price) {
+			break
+		}
+
+		quantity := math.Min(amount, level.Amount)
+		matched = append(matched, BookLevel{Price: level.Price, Amount: quantity})
+
+		amount -= quantity
+		level.Amount -= quantity
+
+		if level.Amount <= 0 {
+			*levels = (*levels)[1:]
+		}
+	}
+
+	return matched
+}
@@ -0,0 +1,136 @@
+package exchange
+
+import (
+	"math"
+
+	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/errors"
+	"github.com/quick-trade/xoney/internal"
+)
+
+// stepTolerance is the relative error tolerated when checking that
+// a value is a multiple of a step, to absorb floating point errors.
+const stepTolerance = 1e-9
+
+// TradingRules holds the restrictions an exchange imposes on orders of a symbol.
+// Zero values mean that the corresponding restriction is not applied.
+type TradingRules struct {
+	TickSize    float64 // Minimum price increment.
+	LotSize     float64 // Minimum amount increment.
+	MinNotional float64 // Minimum value of an order in the quote currency.
+}
+
+// RoundPrice rounds the price to the nearest multiple of the tick size.
+func (r TradingRules) RoundPrice(price float64) float64 {
+	if r.TickSize <= 0 {
+		return price
+	}
+
+	return math.Round(price/r.TickSize) * r.TickSize
+}
+
+// RoundAmount rounds the amount down to a multiple of the lot size,
+// so that an order never exceeds the intended amount.
+func (r TradingRules) RoundAmount(amount float64) float64 {
+	if r.LotSize <= 0 {
+		return amount
+	}
+
+	return math.Floor(amount/r.LotSize+stepTolerance) * r.LotSize
+}
+
+// Validate checks that the prices of the order are multiples of the tick size,
+// the amount is a multiple of the lot size and the notional is not below
+// the minimum. Prices of market and trailing stop orders are not checked,
+// since they are not sent to the exchange.
+func (r TradingRules) Validate(order Order) error {
+	if order.orderType != Market && order.orderType != TrailingStop {
+		for _, price := range [...]float64{order.price, order.stopPrice} {
+			if price != 0 && !isMultiple(price, r.TickSize) {
+				return errors.NewPriceTickError(price, r.TickSize)
+			}
+		}
+	}
+
+	if !isMultiple(order.amount, r.LotSize) {
+		return errors.NewLotSizeError(order.amount, r.LotSize)
+	}
+
+	if notional := order.amount * order.price; notional < r.MinNotional {
+		return errors.NewMinNotionalError(notional, r.MinNotional)
+	}
+
+	return nil
+}
+
+// Round rounds the prices and the amount of the order and validates the result.
+// It fails if the rounded amount is zero or below the minimum notional.
+func (r TradingRules) Round(order Order) (Order, error) {
+	if order.orderType != Market && order.orderType != TrailingStop {
+		order.price = r.RoundPrice(order.price)
+		order.stopPrice = r.RoundPrice(order.stopPrice)
+	}
+
+	order.amount = r.RoundAmount(order.amount)
+
+	if order.amount <= 0 {
+		return order, errors.NewInvalidOrderAmountError(order.amount)
+	}
+
+	return order, r.Validate(order)
+}
+
+func isMultiple(value, step float64) bool {
+	if step <= 0 {
+		return true
+	}
+
+	ratio := value / step
+
+	return math.Abs(ratio-math.Round(ratio)) <= stepTolerance*math.Max(1, math.Abs(ratio))
+}
+
+// RulesRegistry holds the trading rules of instruments by their symbols.
+// Orders of symbols without registered rules are not restricted.
+type RulesRegistry struct {
+	rules map[data.Symbol]TradingRules
+}
+
+// NewRulesRegistry creates an empty RulesRegistry.
+func NewRulesRegistry() *RulesRegistry {
+	return &RulesRegistry{
+		rules: make(map[data.Symbol]TradingRules, internal.DefaultCapacity),
+	}
+}
+
+// Register sets the trading rules of the symbol.
+func (r *RulesRegistry) Register(symbol data.Symbol, rules TradingRules) {
+	r.rules[symbol] = rules
+}
+
+// Rules returns the trading rules of the symbol and whether they are registered.
+func (r *RulesRegistry) Rules(symbol data.Symbol) (TradingRules, bool) {
+	rules, ok := r.rules[symbol]
+
+	return rules, ok
+}
+
+// Validate checks the order against the rules of its symbol.
+func (r *RulesRegistry) Validate(order Order) error {
+	rules, ok := r.rules[order.symbol]
+	if !ok {
+		return nil
+	}
+
+	return rules.Validate(order)
+}
+
+// Round rounds the order according to the rules of its symbol.
+func (r *RulesRegistry) Round(order Order) (Order, error) {
+	rules, ok := r.rules[order.symbol]
+	if !ok {
+		return order, nil
+	}
+
+	return rules.Round(order)
+}
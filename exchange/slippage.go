@@ -0,0 +1,88 @@
+package exchange
+
+import (
+	"math"
+
+	"github.com/quick-trade/xoney/common/data"
+)
+
+const basisPoint = 1e-4
+
+// SlippageModel estimates the adverse price movement caused by executing
+// an order against the market. It is applied to market orders and to
+// triggered stop, take-profit and trailing stop orders; limit orders are
+// filled exactly at their price.
+type SlippageModel interface {
+	// Slippage returns the relative price impact of executing the order during
+	// the candle, e.g. 0.001 means that a buy order is filled 0.1% higher.
+	Slippage(order Order, candle data.Candle) float64
+}
+
+// NoSlippage fills orders exactly at the requested price.
+type NoSlippage struct{}
+
+func (NoSlippage) Slippage(Order, data.Candle) float64 { return 0 }
+
+// FixedSlippage shifts every fill by a constant number of basis points.
+type FixedSlippage struct {
+	BPS float64
+}
+
+func (f FixedSlippage) Slippage(Order, data.Candle) float64 {
+	return f.BPS * basisPoint
+}
+
+func NewFixedSlippage(bps float64) *FixedSlippage {
+	return &FixedSlippage{BPS: bps}
+}
+
+// VolatilitySlippage scales slippage with the relative range of the candle:
+// Factor * (High - Low) / Close.
+type VolatilitySlippage struct {
+	Factor float64
+}
+
+func (v VolatilitySlippage) Slippage(_ Order, candle data.Candle) float64 {
+	return v.Factor * relativeRange(candle)
+}
+
+func NewVolatilitySlippage(factor float64) *VolatilitySlippage {
+	return &VolatilitySlippage{Factor: factor}
+}
+
+// VolumeSlippage implements the square-root market impact model:
+// Factor * σ * sqrt(amount / volume), where σ is the relative range of the
+// candle. Orders are treated as the whole candle volume if the volume is unknown.
+type VolumeSlippage struct {
+	Factor float64
+}
+
+func (v VolumeSlippage) Slippage(order Order, candle data.Candle) float64 {
+	participation := 1.0
+	if candle.Volume > 0 {
+		participation = order.amount / candle.Volume
+	}
+
+	return v.Factor * relativeRange(candle) * math.Sqrt(participation)
+}
+
+func NewVolumeSlippage(factor float64) *VolumeSlippage {
+	return &VolumeSlippage{Factor: factor}
+}
+
+func relativeRange(candle data.Candle) float64 {
+	if candle.Close <= 0 {
+		return 0
+	}
+
+	return (candle.High - candle.Low) / candle.Close
+}
+
+// slippedPrice moves the price against the order by the relative impact.
+func slippedPrice(side OrderSide, price, impact float64) float64 {
+	if side == Buy {
+		return price * (1 + impact)
+	}
+
+	return price * (1 - impact)
+}
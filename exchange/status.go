@@ -0,0 +1,30 @@
+package exchange
+
+import "time"
+
+// OrderStatus describes the state of an order on the exchange.
+type OrderStatus string
+
+const (
+	Open            OrderStatus = "open"             // The order is waiting for execution.
+	PartiallyFilled OrderStatus = "partially_filled" // A part of the order is executed, the rest is waiting.
+	Filled          OrderStatus = "filled"           // The order is executed entirely.
+	Canceled        OrderStatus = "canceled"         // The order is cancelled before execution.
+	Expired         OrderStatus = "expired"          // The order has reached the end of its time in force.
+	Rejected        OrderStatus = "rejected"         // The order is not accepted by the exchange.
+)
+
+// OrderUpdate reports a change of the order status at some moment.
+type OrderUpdate struct {
+	Order  Order
+	Status OrderStatus
+	Time   time.Time
+}
+
+func NewOrderUpdate(order Order, status OrderStatus, moment time.Time) *OrderUpdate {
+	return &OrderUpdate{
+		Order:  order,
+		Status: status,
+		Time:   moment,
+	}
+}
@@ -1,8 +1,10 @@
 package executing
 
 import (
+	goErrors "errors"
 	"fmt"
 
+	"github.com/quick-trade/xoney/errors"
 	"github.com/quick-trade/xoney/events"
 	"github.com/quick-trade/xoney/exchange"
 )
@@ -22,3 +24,10 @@ func ProcessEvent(connector exchange.Connector, event events.Event) error {
 
 	return nil
 }
+
+// IsRejection reports whether the error is a rejection of an order by the connector.
+// The rejection is also reported to the system as an order update, so it does not
+// stop the execution.
+func IsRejection(err error) bool {
+	return goErrors.As(err, &errors.OrderRejectedError{})
+}
@@ -0,0 +1,67 @@
+package executing
+
+import (
+	"fmt"
+
+	"github.com/quick-trade/xoney/exchange"
+	st "github.com/quick-trade/xoney/strategy"
+)
+
+// Notify delivers the order updates and executions reported by the connector to
+// the system if it implements st.OrderObserver or st.FillObserver, and processes
+// the events produced in response. Notifications are consumed even if the system
+// does not observe them, so that they do not pile up in the connector.
+func Notify(connector exchange.Connector, system st.Tradable) error {
+	updates := drain(connector.OrderUpdates())
+	fills := drain(connector.FillStream())
+
+	if observer, ok := system.(st.OrderObserver); ok {
+		for _, update := range updates {
+			event, err := observer.OnOrderUpdate(update)
+			if err != nil {
+				return fmt.Errorf("failed to handle order update: %w", err)
+			}
+
+			if err := ProcessEvent(connector, event); err != nil && !IsRejection(err) {
+				return err
+			}
+		}
+	}
+
+	if observer, ok := system.(st.FillObserver); ok {
+		for _, fill := range fills {
+			event, err := observer.OnFill(fill)
+			if err != nil {
+				return fmt.Errorf("failed to handle fill: %w", err)
+			}
+
+			if err := ProcessEvent(connector, event); err != nil && !IsRejection(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// drain reads all values currently available in the channel without blocking.
+func drain[T any](channel <-chan T) []T {
+	values := make([]T, 0)
+
+	if channel == nil {
+		return values
+	}
+
+	for {
+		select {
+		case value, ok := <-channel:
+			if !ok {
+				return values
+			}
+
+			values = append(values, value)
+		default:
+			return values
+		}
+	}
+}
@@ -44,3 +44,14 @@ func Diff(sample Data) Data {
 
 	return diff
 }
+
+// Returns calculates the simple returns of the series: the relative
+// changes between consecutive values.
+func Returns(sample Data) Data {
+	returns := make(Data, 0, len(sample))
+	for i := 1; i < len(sample); i++ {
+		returns = Append(returns, sample[i]/sample[i-1]-1)
+	}
+
+	return returns
+}
@@ -65,6 +65,10 @@ func (e *Executor) execute(ctx context.Context) error {
 	candleFlow := e.listenCandles(ctx)
 
 	for candle := range candleFlow {
+		if err := exec.Notify(e.connector, e.system); err != nil {
+			return err
+		}
+
 		event, err := e.system.Next(candle)
 		if err != nil {
 			return err
@@ -73,6 +77,10 @@ func (e *Executor) execute(ctx context.Context) error {
 		if err := exec.ProcessEvent(e.connector, event); err != nil {
 			return err
 		}
+
+		if err := exec.Notify(e.connector, e.system); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -34,3 +34,16 @@ type VectorizedTradable interface {
 		charts data.ChartContainer,
 	) (data.Equity, error)
 }
+
+// FillObserver is an optional interface of a Tradable that is notified
+// about every execution of its orders reported by the connector.
+type FillObserver interface {
+	OnFill(fill exchange.Fill) (events.Event, error)
+}
+
+// OrderObserver is an optional interface of a Tradable that is notified
+// about changes of its order statuses: executions, cancellations,
+// expirations and rejections.
+type OrderObserver interface {
+	OnOrderUpdate(update exchange.OrderUpdate) (events.Event, error)
+}
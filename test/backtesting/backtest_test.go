@@ -69,6 +69,10 @@ func TestBacktestReturnsEquity(t *testing.T) {
 		t.Error(err.Error())
 	}
 
+	if len(equity.Deposit()) == 0 {
+		t.Fatal("Expected the equity of the backtest, got an empty one")
+	}
+
 	history := equity.Deposit()
 	balanceHistory := equity.PortfolioHistory()
 	balanceHistory[data.NewCurrency("Total", "")] = history
@@ -81,3 +85,70 @@ func TestBacktestReturnsEquity(t *testing.T) {
 		t.Error(err.Error())
 	}
 }
+
+func TestBacktestJournal(t *testing.T) {
+	currency := data.NewCurrency("USD", "BINANCE")
+	portfolio := common.NewPortfolio(currency)
+	portfolio.Set(currency, 17100)
+
+	simulator := exchange.NewMarginSimulator(portfolio, 0.001)
+	tester := bt.NewBacktester(&simulator)
+
+	system := btcStrategy()
+
+	if _, err := tester.Backtest(charts, &system); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	journal := tester.Journal()
+	if len(journal) == 0 {
+		t.Fatal("Expected the strategy trades to be recorded in the journal")
+	}
+
+	for i := 1; i < len(journal); i++ {
+		if journal[i].Time.Before(journal[i-1].Time) {
+			t.Fatalf("Journal is not ordered by time at %d", i)
+		}
+	}
+
+	for _, fill := range journal {
+		if fill.Symbol != btc15m.Symbol() || fill.Fee <= 0 {
+			t.Errorf("Unexpected fill: %+v", fill)
+		}
+	}
+}
+
+func TestBacktestCostsHistory(t *testing.T) {
+	currency := data.NewCurrency("USD", "BINANCE")
+	portfolio := common.NewPortfolio(currency)
+	portfolio.Set(currency, 17100)
+
+	simulator := exchange.NewMarginSimulator(portfolio, 0.001)
+	tester := bt.NewBacktester(&simulator)
+
+	system := btcStrategy()
+
+	equity, err := tester.Backtest(charts, &system)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	commission, ok := equity.CostsHistory()[string(exchange.CommissionCost)]
+	if !ok {
+		t.Fatal("Expected commission series to be recorded next to equity")
+	}
+
+	if len(commission) != len(equity.Deposit()) {
+		t.Fatalf("Expected one cost record per equity value, got %d and %d", len(commission), len(equity.Deposit()))
+	}
+
+	for i := 1; i < len(commission); i++ {
+		if commission[i] < commission[i-1] {
+			t.Fatalf("Accumulated commission decreased at %d", i)
+		}
+	}
+
+	if last := commission[len(commission)-1]; last != simulator.Costs()[exchange.CommissionCost] {
+		t.Errorf("Expected the last record to match simulator costs, got: %v", last)
+	}
+}
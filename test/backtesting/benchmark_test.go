@@ -0,0 +1,120 @@
+package backtesting_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	bt "github.com/quick-trade/xoney/backtest"
+	"github.com/quick-trade/xoney/common/data"
+)
+
+var benchmarkReturns = []float64{0.01, -0.02, 0.03, -0.01, 0.02}
+
+// benchmarkChart returns a 30m chart with the benchmark returns between
+// the hours and unrelated prices at the half hours.
+func benchmarkChart() data.Chart {
+	timeframe, _ := data.NewTimeFrame(30*time.Minute, "30m")
+	chart := data.RawChart(*timeframe, 2*len(benchmarkReturns)+1)
+
+	price := 100.0
+
+	for i := 0; i <= len(benchmarkReturns); i++ {
+		moment := latencyStart().Add(time.Duration(i) * time.Hour)
+		chart.Add(*data.NewCandle(price, price, price, price, 0, moment))
+
+		if i == len(benchmarkReturns) {
+			break
+		}
+
+		chart.Add(*data.NewCandle(1, 1, 1, 1, 0, moment.Add(30*time.Minute)))
+		price *= 1 + benchmarkReturns[i]
+	}
+
+	return chart
+}
+
+// leveragedEquity returns an hourly equity with the returns equal
+// to the doubled returns of the benchmark plus 0.001.
+func leveragedEquity() data.Equity {
+	returns := make([]float64, len(benchmarkReturns))
+	for i, value := range benchmarkReturns {
+		returns[i] = 2*value + 0.001
+	}
+
+	return equityFromReturns(returns...)
+}
+
+func standardDeviation(values []float64) float64 {
+	mean := 0.0
+	for _, value := range values {
+		mean += value / float64(len(values))
+	}
+
+	variance := 0.0
+	for _, value := range values {
+		variance += (value - mean) * (value - mean) / float64(len(values))
+	}
+
+	return math.Sqrt(variance)
+}
+
+func TestBenchmarkMetrics(t *testing.T) {
+	benchmark := bt.NewChartBenchmark(benchmarkChart())
+	equity := leveragedEquity()
+	inYear := equity.Timeframe().CandlesPerYear
+
+	trackingError := standardDeviation(benchmarkReturns) * math.Sqrt(inYear)
+
+	cases := []struct {
+		name     string
+		metric   bt.Metric
+		expected float64
+	}{
+		{name: "beta", metric: bt.Beta{Benchmark: benchmark}, expected: 2},
+		{name: "alpha", metric: bt.Alpha{Benchmark: benchmark}, expected: 0.001 * inYear},
+		{name: "alpha with risk-free rate", metric: bt.Alpha{Benchmark: benchmark, RF: 0.05}, expected: 0.001*inYear + 0.05},
+		{name: "correlation", metric: bt.Correlation{Benchmark: benchmark}, expected: 1},
+		{name: "tracking error", metric: bt.TrackingError{Benchmark: benchmark}, expected: trackingError},
+		{name: "information ratio", metric: bt.InformationRatio{Benchmark: benchmark}, expected: (0.006 + 0.001) * inYear / trackingError},
+		{name: "up capture", metric: bt.UpCapture{Benchmark: benchmark}, expected: 0.123 / 0.06},
+		{name: "down capture", metric: bt.DownCapture{Benchmark: benchmark}, expected: 0.058 / 0.03},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if value := tc.metric.Evaluate(equity); math.Abs(value-tc.expected) > 1e-6*math.Max(1, math.Abs(tc.expected)) {
+				t.Errorf("Expected %v, got: %v", tc.expected, value)
+			}
+		})
+	}
+}
+
+func TestEquityBenchmark_Itself(t *testing.T) {
+	equity := leveragedEquity()
+	benchmark := bt.NewEquityBenchmark(equity)
+
+	if beta := (bt.Beta{Benchmark: benchmark}).Evaluate(equity); math.Abs(beta-1) > metricsEpsilon {
+		t.Errorf("Expected beta 1, got: %v", beta)
+	}
+
+	if trackingError := (bt.TrackingError{Benchmark: benchmark}).Evaluate(equity); trackingError != 0 {
+		t.Errorf("Expected zero tracking error, got: %v", trackingError)
+	}
+}
+
+func TestBenchmark_StartsLater(t *testing.T) {
+	chart := benchmarkChart()
+	late := chart.Slice(data.NewPeriod(latencyStart().Add(time.Hour), chart.Timestamp.End()))
+
+	// The first hour of the equity is skipped: its return is not related to the benchmark.
+	returns := []float64{0.5}
+	for _, value := range benchmarkReturns[1:] {
+		returns = append(returns, 2*value)
+	}
+
+	beta := (bt.Beta{Benchmark: bt.NewChartBenchmark(late)}).Evaluate(equityFromReturns(returns...))
+	if math.Abs(beta-2) > 1e-9 {
+		t.Errorf("Expected beta 2, got: %v", beta)
+	}
+}
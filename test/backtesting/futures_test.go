@@ -0,0 +1,74 @@
+package backtesting_test
+
+import (
+	"math"
+	"testing"
+
+	bt "github.com/quick-trade/xoney/backtest"
+	"github.com/quick-trade/xoney/common"
+	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/events"
+	"github.com/quick-trade/xoney/exchange"
+	st "github.com/quick-trade/xoney/strategy"
+)
+
+// holdStrategy opens a position with the first candle and holds it.
+type holdStrategy struct {
+	instrument data.Instrument
+	side       exchange.OrderSide
+	amount     float64
+	entry      float64
+}
+
+func (h *holdStrategy) Start(data.ChartContainer) error {
+	h.entry = 0
+
+	return nil
+}
+
+func (h *holdStrategy) MinDurations() st.Durations {
+	return st.Durations{h.instrument: 0}
+}
+
+func (h *holdStrategy) Next(candle data.InstrumentCandle) (events.Event, error) {
+	if h.entry != 0 {
+		return nil, nil
+	}
+
+	h.entry = candle.Close
+
+	order, err := exchange.NewOrder(h.instrument.Symbol(), exchange.Market, h.side, candle.Close, h.amount)
+	if err != nil {
+		return nil, err
+	}
+
+	return events.NewOpenOrder(*order), nil
+}
+
+func TestBacktestFuturesSimulator(t *testing.T) {
+	currency := data.NewCurrency("USD", "BINANCE")
+	portfolio := common.NewPortfolio(currency)
+	portfolio.Set(currency, 17100)
+
+	simulator := exchange.NewFuturesSimulator(portfolio, 0, exchange.WithLeverage(3))
+	tester := bt.NewBacktester(simulator)
+
+	system := &holdStrategy{instrument: btc15m, side: exchange.Sell, amount: 1}
+
+	equity, err := tester.Backtest(charts, system)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	chart := charts[btc15m]
+	last := chart.Close[len(chart.Close)-1]
+	expected := 17100 - (last - system.entry)
+
+	if now := equity.Now(); math.Abs(now-expected) > 1e-6 {
+		t.Errorf("Expected final equity %v, got: %v", expected, now)
+	}
+
+	if balance := simulator.Portfolio().Balance(btc15m.Symbol().Base()); balance != 0 {
+		t.Errorf("Futures backtest must not hold the base currency, got: %v", balance)
+	}
+}
@@ -0,0 +1,63 @@
+package backtesting_test
+
+import (
+	"fmt"
+	"testing"
+
+	bt "github.com/quick-trade/xoney/backtest"
+	"github.com/quick-trade/xoney/common"
+	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/exchange"
+)
+
+func reproducibleJournal(t *testing.T, ids common.IDGenerator) []exchange.Fill {
+	t.Helper()
+
+	currency := data.NewCurrency("USD", "BINANCE")
+	portfolio := common.NewPortfolio(currency)
+	portfolio.Set(currency, 17100)
+
+	simulator := exchange.NewMarginSimulator(portfolio, 0.001)
+	tester := bt.NewBacktester(&simulator, bt.WithIDGenerator(ids))
+
+	system := btcStrategy()
+
+	// The strategy appends to the closing prices of its charts, so every run gets its own copy.
+	if _, err := tester.Backtest(getCharts(), &system); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	return tester.Journal()
+}
+
+func TestBacktestWithIDGenerator_ReproducibleJournal(t *testing.T) {
+	t.Cleanup(func() { common.SetIDGenerator(nil) })
+
+	ids := common.NewSequentialIDs(1)
+
+	first := reproducibleJournal(t, ids)
+	second := reproducibleJournal(t, ids)
+
+	if len(first) == 0 {
+		t.Fatal("Expected the strategy trades to be recorded in the journal")
+	}
+
+	if fmt.Sprintf("%+v", first) != fmt.Sprintf("%+v", second) {
+		t.Error("Expected identical journals for identical backtests")
+	}
+
+	if first[0].OrderID != 1 {
+		t.Errorf("Expected the first order to have ID 1, got: %d", first[0].OrderID)
+	}
+}
+
+func TestBacktestWithIDGenerator_RestoresPreviousGenerator(t *testing.T) {
+	common.SetIDGenerator(common.NewSequentialIDs(1000))
+	t.Cleanup(func() { common.SetIDGenerator(nil) })
+
+	reproducibleJournal(t, common.NewSequentialIDs(1))
+
+	if id := common.NextID(); id != 1000 {
+		t.Errorf("Expected the previous generator to be restored untouched, got the ID: %d", id)
+	}
+}
@@ -0,0 +1,192 @@
+package backtesting_test
+
+import (
+	"testing"
+	"time"
+
+	bt "github.com/quick-trade/xoney/backtest"
+	"github.com/quick-trade/xoney/common"
+	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/events"
+	"github.com/quick-trade/xoney/exchange"
+	st "github.com/quick-trade/xoney/strategy"
+)
+
+// buyOnceStrategy buys at the close of the first candle with a market order.
+type buyOnceStrategy struct {
+	instrument data.Instrument
+	placed     bool
+}
+
+func (b *buyOnceStrategy) Start(data.ChartContainer) error { return nil }
+
+func (b *buyOnceStrategy) MinDurations() st.Durations {
+	return st.Durations{b.instrument: 0}
+}
+
+func (b *buyOnceStrategy) Next(candle data.InstrumentCandle) (events.Event, error) {
+	if b.placed {
+		return nil, nil
+	}
+
+	b.placed = true
+
+	order, err := exchange.NewOrder(b.instrument.Symbol(), exchange.Market, exchange.Buy, candle.Close, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return events.NewOpenOrder(*order), nil
+}
+
+func latencyInstrument(duration time.Duration, name string) data.Instrument {
+	symbol := data.NewSymbol("BTC", "USD", "BINANCE")
+	timeframe, _ := data.NewTimeFrame(duration, name)
+
+	return data.NewInstrument(*symbol, *timeframe)
+}
+
+func latencyStart() time.Time {
+	return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// hourlyCharts returns candles closing at 01:00, 02:00, 03:00 and 04:00.
+func hourlyCharts() data.ChartContainer {
+	instrument := latencyInstrument(time.Hour, "1h")
+	chart := data.RawChart(instrument.Timeframe(), 4)
+
+	for i, price := range []float64{100, 110, 120, 130} {
+		chart.Add(*data.NewCandle(price, price, price, price, 10, latencyStart().Add(time.Duration(i+1)*time.Hour)))
+	}
+
+	return data.ChartContainer{instrument: chart}
+}
+
+// halfHourCharts returns candles closing every 30 minutes from 00:30 to 04:00.
+func halfHourCharts() data.ChartContainer {
+	instrument := latencyInstrument(30*time.Minute, "30m")
+	chart := data.RawChart(instrument.Timeframe(), 8)
+
+	for i, price := range []float64{95, 100, 105, 110, 115, 120, 125, 130} {
+		chart.Add(*data.NewCandle(price, price, price, price, 10, latencyStart().Add(time.Duration(i+1)*30*time.Minute)))
+	}
+
+	return data.ChartContainer{instrument: chart}
+}
+
+func latencyBacktest(t *testing.T, options ...bt.Option) []exchange.Fill {
+	t.Helper()
+
+	currency := data.NewCurrency("USD", "BINANCE")
+	portfolio := common.NewPortfolio(currency)
+	portfolio.Set(currency, 1000)
+
+	simulator := exchange.NewMarginSimulator(portfolio, 0)
+	tester := bt.NewStepByStepBacktester(&simulator, options...)
+
+	charts := hourlyCharts()
+	system := &buyOnceStrategy{instrument: latencyInstrument(time.Hour, "1h")}
+
+	if err := tester.Start(charts, system); err != nil {
+		t.Fatalf("Error starting backtest: %v", err)
+	}
+
+	for _, candle := range charts.Candles() {
+		if err := tester.Next(candle); err != nil {
+			t.Fatalf("Error during backtest: %v", err)
+		}
+	}
+
+	return tester.Journal()
+}
+
+func TestStepByStepBacktester_Latency(t *testing.T) {
+	cases := []struct {
+		name    string
+		options []bt.Option
+		price   float64
+		time    time.Time
+	}{
+		{
+			name:  "without latency",
+			price: 100,
+			time:  latencyStart().Add(time.Hour),
+		},
+		{
+			name:    "zero latency",
+			options: []bt.Option{bt.WithLatency(bt.FixedLatency(0))},
+			price:   100,
+			time:    latencyStart().Add(time.Hour),
+		},
+		{
+			// Arrives at 02:30 and is executed after the candle closing at 03:00.
+			name:    "fixed latency",
+			options: []bt.Option{bt.WithLatency(bt.FixedLatency(90 * time.Minute))},
+			price:   120,
+			time:    latencyStart().Add(3 * time.Hour),
+		},
+		{
+			// Arrives at 02:30 and is executed at the close of the 30m candle closing at 02:30.
+			name: "execution charts",
+			options: []bt.Option{
+				bt.WithLatency(bt.FixedLatency(90 * time.Minute)),
+				bt.WithExecutionCharts(halfHourCharts()),
+			},
+			price: 115,
+			time:  latencyStart().Add(150 * time.Minute),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			fills := latencyBacktest(t, tc.options...)
+
+			if len(fills) != 1 {
+				t.Fatalf("Expected one fill, got: %v", fills)
+			}
+
+			if fills[0].Price != tc.price || !fills[0].Time.Equal(tc.time) {
+				t.Errorf("Expected fill at %v at %v, got: %+v", tc.price, tc.time, fills[0])
+			}
+		})
+	}
+}
+
+func TestStepByStepBacktester_PendingEventsAreNotDeliveredAfterEnd(t *testing.T) {
+	fills := latencyBacktest(t, bt.WithLatency(bt.FixedLatency(24*time.Hour)))
+
+	if len(fills) != 0 {
+		t.Errorf("Expected the order not to reach the simulator, got: %v", fills)
+	}
+}
+
+func TestRandomLatency_Reproducible(t *testing.T) {
+	first, err := bt.NewRandomLatency(10*time.Millisecond, 200*time.Millisecond, 42)
+	if err != nil {
+		t.Fatalf("Error creating latency model: %v", err)
+	}
+
+	second, _ := bt.NewRandomLatency(10*time.Millisecond, 200*time.Millisecond, 42)
+
+	for i := 0; i < 100; i++ {
+		delay := first.Delay()
+
+		if delay < 10*time.Millisecond || delay > 200*time.Millisecond {
+			t.Fatalf("Delay out of bounds: %v", delay)
+		}
+
+		if other := second.Delay(); other != delay {
+			t.Fatalf("Expected equal delays for the same seed, got: %v and %v", delay, other)
+		}
+	}
+}
+
+func TestNewRandomLatency_InvalidBounds(t *testing.T) {
+	if _, err := bt.NewRandomLatency(-time.Second, time.Second, 0); err == nil {
+		t.Error("Expected error for negative minimal latency")
+	}
+
+	if _, err := bt.NewRandomLatency(time.Second, time.Millisecond, 0); err == nil {
+		t.Error("Expected error for maximal latency below the minimal one")
+	}
+}
@@ -0,0 +1,183 @@
+package backtesting_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	bt "github.com/quick-trade/xoney/backtest"
+	"github.com/quick-trade/xoney/common/data"
+)
+
+const metricsEpsilon = 1e-9
+
+// hourlyEquity returns an equity with the values recorded every hour.
+func hourlyEquity(values ...float64) data.Equity {
+	timeframe, _ := data.NewTimeFrame(time.Hour, "1h")
+	equity := data.NewEquity(*timeframe, len(values))
+
+	for i, value := range values {
+		equity.AddValue(value, latencyStart().Add(time.Duration(i)*time.Hour))
+	}
+
+	return *equity
+}
+
+func TestUnderwater(t *testing.T) {
+	equity := hourlyEquity(100, 120, 90, 110, 130, 117, 125)
+	expected := []float64{0, 0, -0.25, 110.0/120 - 1, 0, -0.1, 125.0/130 - 1}
+
+	underwater := bt.Underwater(equity)
+	if len(underwater) != equity.Timestamp.Len() {
+		t.Fatalf("Expected %d values aligned with the timestamps, got: %d", equity.Timestamp.Len(), len(underwater))
+	}
+
+	for i, value := range expected {
+		if math.Abs(underwater[i]-value) > metricsEpsilon {
+			t.Errorf("Expected drawdown %v at %d, got: %v", value, i, underwater[i])
+		}
+	}
+}
+
+func TestDrawdownMetrics(t *testing.T) {
+	equity := hourlyEquity(100, 120, 90, 110, 130, 117, 125)
+
+	cases := []struct {
+		name     string
+		metric   bt.Metric
+		expected float64
+	}{
+		{name: "max drawdown", metric: bt.MaxDrawdown{}, expected: 0.25},
+		{name: "average drawdown", metric: bt.AverageDrawdown{}, expected: 0.175},
+		{name: "drawdown duration", metric: bt.DrawdownDuration{}, expected: float64(3 * time.Hour)},
+		{name: "recovery time", metric: bt.RecoveryTime{}, expected: float64(2 * time.Hour)},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if value := tc.metric.Evaluate(equity); math.Abs(value-tc.expected) > metricsEpsilon {
+				t.Errorf("Expected %v, got: %v", tc.expected, value)
+			}
+		})
+	}
+}
+
+func TestDrawdownMetrics_NotRecovered(t *testing.T) {
+	equity := hourlyEquity(100, 110, 80, 70, 90)
+
+	if value := (bt.MaxDrawdown{}).Evaluate(equity); math.Abs(value-(1-70.0/110)) > metricsEpsilon {
+		t.Errorf("Unexpected max drawdown: %v", value)
+	}
+
+	if value := (bt.DrawdownDuration{}).Evaluate(equity); value != float64(3*time.Hour) {
+		t.Errorf("Expected the current drawdown to last 3h, got: %v", time.Duration(value))
+	}
+
+	if value := (bt.RecoveryTime{}).Evaluate(equity); value != float64(time.Hour) {
+		t.Errorf("Expected the time since the trough, got: %v", time.Duration(value))
+	}
+}
+
+func TestDrawdownMetrics_Rising(t *testing.T) {
+	equity := hourlyEquity(100, 101, 102)
+
+	for _, metric := range []bt.Metric{bt.MaxDrawdown{}, bt.AverageDrawdown{}, bt.DrawdownDuration{}, bt.RecoveryTime{}} {
+		if value := metric.Evaluate(equity); value != 0 {
+			t.Errorf("Expected zero for rising equity, %T: %v", metric, value)
+		}
+	}
+}
+
+// equityFromReturns returns an hourly equity starting at 100 with the given simple returns.
+func equityFromReturns(returns ...float64) data.Equity {
+	values := []float64{100}
+	for _, value := range returns {
+		values = append(values, values[len(values)-1]*(1+value))
+	}
+
+	return hourlyEquity(values...)
+}
+
+func TestReturnRatios(t *testing.T) {
+	equity := equityFromReturns(0.02, -0.01, 0.02, -0.01)
+	inYear := equity.Timeframe().CandlesPerYear
+
+	cases := []struct {
+		name     string
+		metric   bt.Metric
+		expected float64
+	}{
+		{
+			name:     "sortino",
+			metric:   bt.Sortino{},
+			expected: 0.005 * inYear / (math.Sqrt(0.0002/4) * math.Sqrt(inYear)),
+		},
+		{name: "calmar", metric: bt.Calmar{}, expected: 0.005 * inYear / 0.01},
+		{name: "omega", metric: bt.Omega{}, expected: 2},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if value := tc.metric.Evaluate(equity); math.Abs(value-tc.expected) > 1e-6*math.Abs(tc.expected) {
+				t.Errorf("Expected %v, got: %v", tc.expected, value)
+			}
+		})
+	}
+}
+
+func TestSortino_Target(t *testing.T) {
+	equity := equityFromReturns(0.02, -0.01, 0.02, -0.01)
+
+	if (bt.Sortino{Target: 10}).Evaluate(equity) >= (bt.Sortino{}).Evaluate(equity) {
+		t.Error("Expected a higher target to decrease the Sortino ratio")
+	}
+}
+
+func TestValueAtRisk(t *testing.T) {
+	equity := equityFromReturns(-0.05, 0.01, -0.03, 0.02, 0, 0.05, -0.01, 0.03, 0.02, 0.04)
+
+	// The tail at 80% confidence holds the two worst returns: -0.05 and -0.03.
+	if value := (bt.VaR{Confidence: 0.8, Method: bt.HistoricalVaR}).Evaluate(equity); math.Abs(value-0.03) > metricsEpsilon {
+		t.Errorf("Expected historical VaR 0.03, got: %v", value)
+	}
+
+	if value := (bt.CVaR{Confidence: 0.8, Method: bt.HistoricalVaR}).Evaluate(equity); math.Abs(value-0.04) > metricsEpsilon {
+		t.Errorf("Expected historical CVaR 0.04, got: %v", value)
+	}
+
+	mean, std := 0.008, math.Sqrt(0.000876)
+	z := -0.8416212335729143
+
+	parametric := (bt.VaR{Confidence: 0.8, Method: bt.ParametricVaR}).Evaluate(equity)
+	if expected := -(mean + z*std); math.Abs(parametric-expected) > 1e-9 {
+		t.Errorf("Expected parametric VaR %v, got: %v", expected, parametric)
+	}
+
+	for _, method := range []bt.VaRMethod{bt.HistoricalVaR, bt.ParametricVaR, bt.CornishFisherVaR} {
+		valueAtRisk := (bt.VaR{Confidence: 0.9, Method: method}).Evaluate(equity)
+		shortfall := (bt.CVaR{Confidence: 0.9, Method: method}).Evaluate(equity)
+
+		if shortfall < valueAtRisk {
+			t.Errorf("CVaR must not be below VaR for %v: %v < %v", method, shortfall, valueAtRisk)
+		}
+	}
+}
+
+func TestValueAtRisk_CornishFisher(t *testing.T) {
+	// Mostly small gains with rare large losses: negative skewness and fat tails.
+	equity := equityFromReturns(0.01, 0.01, 0.01, -0.08, 0.01, 0.01, 0.01, 0.01, -0.06, 0.01, 0.01, 0.01)
+
+	parametric := (bt.VaR{Confidence: 0.99, Method: bt.ParametricVaR}).Evaluate(equity)
+	adjusted := (bt.VaR{Confidence: 0.99, Method: bt.CornishFisherVaR}).Evaluate(equity)
+
+	if adjusted <= parametric {
+		t.Errorf("Expected Cornish-Fisher VaR to exceed the normal one for negative skewness: %v <= %v", adjusted, parametric)
+	}
+
+	normal := (bt.CVaR{Confidence: 0.99, Method: bt.ParametricVaR}).Evaluate(equity)
+	shortfall := (bt.CVaR{Confidence: 0.99, Method: bt.CornishFisherVaR}).Evaluate(equity)
+
+	if shortfall <= normal || shortfall < adjusted {
+		t.Errorf("Unexpected Cornish-Fisher CVaR %v, normal CVaR %v, VaR %v", shortfall, normal, adjusted)
+	}
+}
@@ -0,0 +1,178 @@
+package backtesting_test
+
+import (
+	goErrors "errors"
+	"testing"
+	"time"
+
+	bt "github.com/quick-trade/xoney/backtest"
+	"github.com/quick-trade/xoney/common"
+	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/errors"
+	"github.com/quick-trade/xoney/events"
+	"github.com/quick-trade/xoney/exchange"
+	st "github.com/quick-trade/xoney/strategy"
+)
+
+type observingStrategy struct {
+	instrument data.Instrument
+	placed     bool
+	fills      []exchange.Fill
+	updates    []exchange.OrderUpdate
+}
+
+func (o *observingStrategy) Start(data.ChartContainer) error { return nil }
+
+func (o *observingStrategy) MinDurations() st.Durations {
+	return st.Durations{o.instrument: 0}
+}
+
+func (o *observingStrategy) Next(candle data.InstrumentCandle) (events.Event, error) {
+	if o.placed {
+		return nil, nil
+	}
+
+	o.placed = true
+
+	order, err := exchange.NewOrder(o.instrument.Symbol(), exchange.Limit, exchange.Buy, 95, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return events.NewOpenOrder(*order), nil
+}
+
+func (o *observingStrategy) OnFill(fill exchange.Fill) (events.Event, error) {
+	o.fills = append(o.fills, fill)
+
+	if fill.Side == exchange.Sell {
+		return nil, nil
+	}
+
+	order, err := exchange.NewOrder(o.instrument.Symbol(), exchange.Market, exchange.Sell, 100, fill.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	return events.NewOpenOrder(*order), nil
+}
+
+func (o *observingStrategy) OnOrderUpdate(update exchange.OrderUpdate) (events.Event, error) {
+	o.updates = append(o.updates, update)
+
+	return nil, nil
+}
+
+func observerCharts(instrument data.Instrument) data.ChartContainer {
+	chart := data.RawChart(instrument.Timeframe(), 3)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	chart.Add(*data.NewCandle(100, 101, 99, 100, 10, start))
+	chart.Add(*data.NewCandle(100, 100, 94, 96, 10, start.Add(time.Hour)))
+	chart.Add(*data.NewCandle(96, 100, 95, 100, 10, start.Add(2*time.Hour)))
+
+	return data.ChartContainer{instrument: chart}
+}
+
+func TestStepByStepBacktester_NotifiesObservers(t *testing.T) {
+	currency := data.NewCurrency("USD", "BINANCE")
+	portfolio := common.NewPortfolio(currency)
+	portfolio.Set(currency, 1000)
+
+	simulator := exchange.NewMarginSimulator(portfolio, 0)
+	tester := bt.NewStepByStepBacktester(&simulator)
+
+	instrument := btc15min()
+	system := &observingStrategy{instrument: instrument}
+	charts := observerCharts(instrument)
+
+	if err := tester.Start(charts, system); err != nil {
+		t.Fatalf("Error starting backtest: %v", err)
+	}
+
+	for _, candle := range charts.Candles() {
+		if err := tester.Next(candle); err != nil {
+			t.Fatalf("Error during backtest: %v", err)
+		}
+	}
+
+	if len(system.fills) != 2 || system.fills[0].Side != exchange.Buy || system.fills[1].Side != exchange.Sell {
+		t.Fatalf("Expected entry and exit fills to be observed, got: %v", system.fills)
+	}
+
+	if len(system.updates) != 2 {
+		t.Fatalf("Expected two order updates, got: %v", system.updates)
+	}
+
+	for _, update := range system.updates {
+		if update.Status != exchange.Filled {
+			t.Errorf("Unexpected order update: %+v", update)
+		}
+	}
+
+	if balance := simulator.Portfolio().Balance(currency); balance != 1005 {
+		t.Errorf("Expected USD balance 1005 after the round trip, got: %v", balance)
+	}
+}
+
+// rejectionObserver buys once and records the updates of its orders.
+type rejectionObserver struct {
+	buyOnceStrategy
+	updates []exchange.OrderUpdate
+}
+
+func (r *rejectionObserver) OnOrderUpdate(update exchange.OrderUpdate) (events.Event, error) {
+	r.updates = append(r.updates, update)
+
+	return nil, nil
+}
+
+func TestBacktester_ContinuesAfterRejectedOrder(t *testing.T) {
+	currency := data.NewCurrency("USD", "BINANCE")
+	portfolio := common.NewPortfolio(currency)
+	portfolio.Set(currency, 50)
+
+	simulator := exchange.NewSpotSimulator(portfolio, 0)
+	tester := bt.NewBacktester(&simulator)
+
+	system := &rejectionObserver{buyOnceStrategy: buyOnceStrategy{instrument: latencyInstrument(time.Hour, "1h")}}
+	charts := hourlyCharts()
+
+	equity, err := tester.Backtest(charts, system)
+	if err != nil {
+		t.Fatalf("Expected the backtest to continue after the rejection, got: %v", err)
+	}
+
+	if len(system.updates) != 1 || system.updates[0].Status != exchange.Rejected {
+		t.Errorf("Expected the strategy to be notified about the rejection, got: %v", system.updates)
+	}
+
+	chart := charts[system.instrument]
+	if length := len(equity.Deposit()); length != chart.Len() {
+		t.Errorf("Expected the equity of every candle, got %d values", length)
+	}
+}
+
+type failingStrategy struct {
+	buyOnceStrategy
+}
+
+func (f *failingStrategy) Next(data.InstrumentCandle) (events.Event, error) {
+	return nil, errors.NewZeroLengthError("signals")
+}
+
+func TestBacktester_ReturnsStrategyErrors(t *testing.T) {
+	currency := data.NewCurrency("USD", "BINANCE")
+	portfolio := common.NewPortfolio(currency)
+	portfolio.Set(currency, 1000)
+
+	simulator := exchange.NewSpotSimulator(portfolio, 0)
+	tester := bt.NewBacktester(&simulator)
+
+	system := &failingStrategy{buyOnceStrategy{instrument: latencyInstrument(time.Hour, "1h")}}
+
+	_, err := tester.Backtest(hourlyCharts(), system)
+	if !goErrors.As(err, &errors.ZeroLengthError{}) {
+		t.Fatalf("Expected the error of the strategy, got: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package backtesting_test
+
+import (
+	goErrors "errors"
+	"math"
+	"testing"
+	"time"
+
+	bt "github.com/quick-trade/xoney/backtest"
+	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/errors"
+)
+
+// alternatingEquity returns an equity with the returns 0.02 and -0.01 repeated
+// the given number of times. The returns have zero skewness, the excess kurtosis
+// of -2 and the Sharpe ratio of one candle equal to 1/3.
+func alternatingEquity(pairs int) data.Equity {
+	returns := make([]float64, 0, 2*pairs)
+	for i := 0; i < pairs; i++ {
+		returns = append(returns, 0.02, -0.01)
+	}
+
+	return equityFromReturns(returns...)
+}
+
+func normalCDF(z float64) float64 { return (1 + math.Erf(z/math.Sqrt2)) / 2 }
+
+func TestProbabilisticSharpe(t *testing.T) {
+	equity := alternatingEquity(5)
+	scale := math.Sqrt(equity.Timeframe().CandlesPerYear)
+
+	// The estimated Sharpe ratio 1/3 is multiplied by the square root of nine returns.
+	if psr := (bt.ProbabilisticSharpe{}).Evaluate(equity); math.Abs(psr-normalCDF(1)) > metricsEpsilon {
+		t.Errorf("Expected %v, got: %v", normalCDF(1), psr)
+	}
+
+	if psr := (bt.ProbabilisticSharpe{Benchmark: scale / 3}).Evaluate(equity); math.Abs(psr-0.5) > metricsEpsilon {
+		t.Errorf("Expected 0.5 for the benchmark equal to the Sharpe ratio, got: %v", psr)
+	}
+
+	longer := (bt.ProbabilisticSharpe{}).Evaluate(alternatingEquity(50))
+	if longer <= normalCDF(1) {
+		t.Errorf("Expected a longer equity to be more significant, got: %v", longer)
+	}
+}
+
+func TestDeflatedSharpe(t *testing.T) {
+	equity := alternatingEquity(20)
+	scale := math.Sqrt(equity.Timeframe().CandlesPerYear)
+	psr := (bt.ProbabilisticSharpe{}).Evaluate(equity)
+
+	if dsr := (bt.DeflatedSharpe{Trials: 1, Variance: scale * scale}).Evaluate(equity); math.Abs(dsr-psr) > metricsEpsilon {
+		t.Errorf("Expected a single trial to give the probabilistic Sharpe ratio %v, got: %v", psr, dsr)
+	}
+
+	// The variance of the Sharpe ratios of one candle is 0.01.
+	variance := 0.01 * scale * scale
+	previous := psr
+
+	for _, trials := range []int{10, 100, 1000} {
+		dsr := (bt.DeflatedSharpe{Trials: trials, Variance: variance}).Evaluate(equity)
+
+		if dsr >= previous || dsr <= 0 {
+			t.Errorf("Expected the deflated Sharpe ratio of %d trials to decrease below %v, got: %v", trials, previous, dsr)
+		}
+
+		previous = dsr
+	}
+}
+
+func TestMinTrackRecordLength(t *testing.T) {
+	equity := alternatingEquity(5)
+
+	// 1 + (z(0.95) * 3)^2 = 25.35 candles.
+	length, err := bt.MinTrackRecordLength(equity, 0, 0.95)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if length != 26*time.Hour {
+		t.Errorf("Expected 26h, got: %v", length)
+	}
+
+	// The equity of the minimum length is significant with the confidence.
+	if psr := (bt.ProbabilisticSharpe{}).Evaluate(alternatingEquity(13)); psr < 0.95 {
+		t.Errorf("Expected the probabilistic Sharpe ratio above 0.95, got: %v", psr)
+	}
+
+	scale := math.Sqrt(equity.Timeframe().CandlesPerYear)
+
+	_, err = bt.MinTrackRecordLength(equity, scale/2, 0.95)
+	if !goErrors.As(err, &errors.InsufficientSharpeError{}) {
+		t.Errorf("Expected InsufficientSharpeError, got: %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package backtesting_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	bt "github.com/quick-trade/xoney/backtest"
+	"github.com/quick-trade/xoney/common"
+	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/exchange"
+)
+
+func tradeFill(side exchange.OrderSide, price, amount, fee float64, hours int) exchange.Fill {
+	instrument := btc15min()
+	symbol := instrument.Symbol()
+
+	return exchange.Fill{
+		Symbol:      symbol,
+		Side:        side,
+		Price:       price,
+		Amount:      amount,
+		Fee:         fee,
+		FeeCurrency: symbol.Quote(),
+		Liquidity:   exchange.Taker,
+		Time:        latencyStart().Add(time.Duration(hours) * time.Hour),
+	}
+}
+
+func TestRoundTrips(t *testing.T) {
+	fills := []exchange.Fill{
+		tradeFill(exchange.Buy, 100, 1, 1, 0),
+		tradeFill(exchange.Buy, 110, 1, 1, 1),
+		tradeFill(exchange.Sell, 120, 1, 1, 2),
+		// Reverses the position: closes the long trade and opens a short one.
+		tradeFill(exchange.Sell, 100, 3, 3, 3),
+		tradeFill(exchange.Buy, 90, 2, 2, 5),
+	}
+
+	trips := bt.RoundTrips(fills)
+	if len(trips) != 2 {
+		t.Fatalf("Expected two round trips, got: %+v", trips)
+	}
+
+	long, short := trips[0], trips[1]
+
+	if long.Side != exchange.Buy || long.Amount != 2 || long.EntryPrice != 105 || long.ExitPrice != 110 {
+		t.Errorf("Unexpected long trade: %+v", long)
+	}
+
+	// Profit of 10 minus the entry fees, the exit fee and a third of the reversing fill fee.
+	if math.Abs(long.PnL-6) > metricsEpsilon || long.HoldingTime() != 3*time.Hour {
+		t.Errorf("Unexpected long trade result: %+v", long)
+	}
+
+	if short.Side != exchange.Sell || short.Amount != 2 || short.EntryPrice != 100 || short.ExitPrice != 90 {
+		t.Errorf("Unexpected short trade: %+v", short)
+	}
+
+	if math.Abs(short.PnL-16) > metricsEpsilon || math.Abs(short.Return()-0.08) > metricsEpsilon {
+		t.Errorf("Unexpected short trade result: %+v", short)
+	}
+}
+
+func TestNewTradeReport(t *testing.T) {
+	fills := []exchange.Fill{
+		tradeFill(exchange.Buy, 100, 1, 0, 0),
+		tradeFill(exchange.Sell, 110, 1, 0, 1),
+		tradeFill(exchange.Buy, 100, 1, 0, 2),
+		tradeFill(exchange.Sell, 95, 1, 0, 4),
+		tradeFill(exchange.Sell, 100, 1, 0, 5),
+		tradeFill(exchange.Buy, 102, 1, 0, 6),
+		tradeFill(exchange.Buy, 100, 1, 0, 8),
+	}
+
+	equity := hourlyEquity(make([]float64, 11)...)
+	report := bt.NewTradeReport(fills, equity)
+
+	expected := bt.TradeReport{
+		Trades:               3,
+		WinRate:              1.0 / 3,
+		AverageWin:           10,
+		AverageLoss:          3.5,
+		ProfitFactor:         10.0 / 7,
+		Expectancy:           1,
+		MaxConsecutiveLosses: 2,
+		AverageHoldingTime:   4 * time.Hour / 3,
+		// Positions are held during 1h, 2h, 1h and the last 2h of the 10h backtest.
+		Exposure: 0.6,
+	}
+
+	if math.Abs(report.WinRate-expected.WinRate) > metricsEpsilon ||
+		math.Abs(report.ProfitFactor-expected.ProfitFactor) > metricsEpsilon ||
+		math.Abs(report.Exposure-expected.Exposure) > metricsEpsilon {
+		t.Fatalf("Expected report %+v, got: %+v", expected, report)
+	}
+
+	report.WinRate, report.ProfitFactor, report.Exposure = expected.WinRate, expected.ProfitFactor, expected.Exposure
+
+	if report != expected {
+		t.Errorf("Expected report %+v, got: %+v", expected, report)
+	}
+}
+
+func TestNewTradeReport_Backtest(t *testing.T) {
+	currency := data.NewCurrency("USD", "BINANCE")
+	portfolio := common.NewPortfolio(currency)
+	portfolio.Set(currency, 17100)
+
+	simulator := exchange.NewMarginSimulator(portfolio, 0.001)
+	tester := bt.NewBacktester(&simulator)
+	system := btcStrategy()
+
+	equity, err := tester.Backtest(getCharts(), &system)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	report := bt.NewTradeReport(tester.Journal(), equity)
+	if report.Trades == 0 || report.Exposure <= 0 || report.Exposure > 1 {
+		t.Errorf("Unexpected report of the backtest: %+v", report)
+	}
+
+	if report.WinRate < 0 || report.WinRate > 1 || report.AverageHoldingTime <= 0 {
+		t.Errorf("Unexpected report of the backtest: %+v", report)
+	}
+}
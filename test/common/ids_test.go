@@ -0,0 +1,124 @@
+package common_test
+
+import (
+	goErrors "errors"
+	"testing"
+
+	"github.com/quick-trade/xoney/common"
+)
+
+func TestSequentialIDs(t *testing.T) {
+	ids := common.NewSequentialIDs(10)
+
+	for expected := uint64(10); expected < 13; expected++ {
+		if id := ids.Next(); id != expected {
+			t.Fatalf("Expected ID %d, got: %d", expected, id)
+		}
+	}
+
+	ids.Reset()
+
+	if id := ids.Next(); id != 10 {
+		t.Errorf("Expected the sequence to restart from 10, got: %d", id)
+	}
+}
+
+func TestSequentialIDs_SkipZero(t *testing.T) {
+	ids := common.NewSequentialIDs(0)
+
+	if id := ids.Next(); id != 1 {
+		t.Fatalf("Expected zero to be skipped, got: %d", id)
+	}
+
+	ids.Reset()
+
+	if id := ids.Next(); id != 1 {
+		t.Errorf("Expected zero to be skipped after reset, got: %d", id)
+	}
+}
+
+func TestSeededIDs(t *testing.T) {
+	first := common.NewSeededIDs(7)
+	second := common.NewSeededIDs(7)
+
+	generated := make([]uint64, 5)
+	for i := range generated {
+		generated[i] = first.Next()
+
+		if id := second.Next(); id != generated[i] {
+			t.Fatalf("Expected equal IDs for the same seed, got: %d and %d", generated[i], id)
+		}
+	}
+
+	first.Reset()
+
+	for i, expected := range generated {
+		if id := first.Next(); id != expected {
+			t.Fatalf("Expected ID %d at %d after reset, got: %d", expected, i, id)
+		}
+	}
+}
+
+func TestSetIDGenerator(t *testing.T) {
+	t.Cleanup(func() { common.SetIDGenerator(nil) })
+
+	common.SetIDGenerator(common.NewSequentialIDs(1))
+
+	if first, second := common.NextID(), common.NextID(); first != 1 || second != 2 {
+		t.Errorf("Expected IDs 1 and 2, got: %d and %d", first, second)
+	}
+
+	common.SetIDGenerator(nil)
+
+	if common.NextID() == common.NextID() {
+		t.Error("Expected random IDs after restoring the default generator")
+	}
+}
+
+func TestUseIDGenerator(t *testing.T) {
+	common.SetIDGenerator(common.NewSequentialIDs(100))
+	t.Cleanup(func() { common.SetIDGenerator(nil) })
+
+	expected := goErrors.New("step failed")
+
+	err := common.UseIDGenerator(common.NewSequentialIDs(1), func() error {
+		if id := common.NextID(); id != 1 {
+			t.Errorf("Expected the installed generator to be used, got the ID: %d", id)
+		}
+
+		return expected
+	})
+	if !goErrors.Is(err, expected) {
+		t.Errorf("Expected the error of the function, got: %v", err)
+	}
+
+	if id := common.NextID(); id != 100 {
+		t.Errorf("Expected the previous generator to be restored, got the ID: %d", id)
+	}
+}
+
+func TestUseIDGenerator_Nested(t *testing.T) {
+	t.Cleanup(func() { common.SetIDGenerator(nil) })
+
+	err := common.UseIDGenerator(common.NewSequentialIDs(1), func() error {
+		err := common.UseIDGenerator(common.NewSequentialIDs(100), func() error {
+			if id := common.NextID(); id != 100 {
+				t.Errorf("Expected the inner generator to be used, got the ID: %d", id)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if id := common.NextID(); id != 1 {
+			t.Errorf("Expected the outer generator to be restored, got the ID: %d", id)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
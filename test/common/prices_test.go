@@ -0,0 +1,82 @@
+package common_test
+
+import (
+	goErrors "errors"
+	"math"
+	"testing"
+
+	"github.com/quick-trade/xoney/common"
+	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/errors"
+)
+
+func btc() data.Currency { return data.NewCurrency("BTC", "EXCHANGE") }
+func eth() data.Currency { return data.NewCurrency("ETH", "EXCHANGE") }
+
+func cryptoGraph() *common.PriceGraph {
+	graph := common.NewPriceGraph()
+	graph.Update(usdPair(btc()), 50000)
+	graph.Update(*data.NewSymbolFromCurrencies(eth(), btc()), 0.05)
+
+	return graph
+}
+
+func TestPriceGraph_CrossRate(t *testing.T) {
+	graph := cryptoGraph()
+
+	price, err := graph.Price(eth(), usd())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if math.Abs(price-2500) > 1e-9 {
+		t.Errorf("Expected ETH price 2500 USD, got: %v", price)
+	}
+
+	inverse, _ := graph.Price(usd(), eth())
+	if math.Abs(inverse-1.0/2500) > 1e-12 {
+		t.Errorf("Expected inverse price %v, got: %v", 1.0/2500, inverse)
+	}
+
+	if _, direct := graph.Rate(eth(), usd()); direct {
+		t.Error("ETH/USD is not traded directly")
+	}
+}
+
+func TestPriceGraph_Prices(t *testing.T) {
+	prices := cryptoGraph().Prices(usd())
+
+	if len(prices) != 2 || prices[btc()] != 50000 || math.Abs(prices[eth()]-2500) > 1e-9 {
+		t.Errorf("Unexpected prices: %v", prices)
+	}
+}
+
+func TestPriceGraph_MissingPath(t *testing.T) {
+	graph := cryptoGraph()
+	sol := data.NewCurrency("SOL", "EXCHANGE")
+
+	_, err := graph.Price(sol, usd())
+	if !goErrors.Is(err, errors.NewNoPriceError(sol.String())) {
+		t.Errorf("Expected NoPriceError, got: %v", err)
+	}
+}
+
+func TestPortfolio_TotalByGraph(t *testing.T) {
+	p := portfolio()
+	p.Set(usd(), 1000)
+	p.Set(eth(), 2)
+	p.Set(btc(), -0.01)
+
+	if _, err := p.Total(map[data.Currency]float64{btc(): 50000}); err == nil {
+		t.Fatal("Expected Total to fail without ETH price")
+	}
+
+	total, err := p.TotalByGraph(cryptoGraph())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if math.Abs(total-5500) > 1e-9 {
+		t.Errorf("Expected total 5500, got: %v", total)
+	}
+}
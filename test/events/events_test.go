@@ -59,6 +59,22 @@ func (m *MockConnector) GetPrices(symbols []data.Symbol) (<-chan exchange.Symbol
 	return nil, nil
 }
 
+func (m *MockConnector) OpenOrders() []exchange.Order {
+	return nil
+}
+
+func (m *MockConnector) OrderStatus(id exchange.OrderID) (exchange.OrderStatus, error) {
+	return exchange.Open, nil
+}
+
+func (m *MockConnector) FillStream() <-chan exchange.Fill {
+	return nil
+}
+
+func (m *MockConnector) OrderUpdates() <-chan exchange.OrderUpdate {
+	return nil
+}
+
 func TestCancelOrder_Occur(t *testing.T) {
 	orderID := exchange.OrderID(123)
 	cancelOrder := events.NewCancelOrder(orderID)
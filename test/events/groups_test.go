@@ -0,0 +1,186 @@
+package events_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/quick-trade/xoney/common"
+	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/events"
+	"github.com/quick-trade/xoney/exchange"
+)
+
+func timeStart() time.Time {
+	return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func marginSimulator() *exchange.MarginSimulator {
+	portfolio := common.NewPortfolio(btcUSD().Quote())
+	portfolio.Set(btcUSD().Quote(), 10000)
+
+	simulator := exchange.NewMarginSimulator(portfolio, 0)
+
+	return &simulator
+}
+
+func updateBTC(t *testing.T, simulator exchange.Simulator, open, high, low, c float64, moment time.Time) {
+	t.Helper()
+
+	timeframe, _ := data.NewTimeFrame(time.Hour, "1h")
+	instrument := data.NewInstrument(btcUSD(), *timeframe)
+
+	candle := data.NewCandle(open, high, low, c, 0, moment)
+	if err := simulator.UpdatePrice(*data.NewInstrumentCandle(*candle, instrument)); err != nil {
+		t.Fatalf("Error updating price: %v", err)
+	}
+}
+
+func TestNewOCO_LinksOrders(t *testing.T) {
+	first, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 90, 1)
+	second, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Sell, 110, 1)
+
+	oco := events.NewOCO(*first, *second)
+
+	for _, order := range oco.Orders() {
+		if order.Group() != first.ID() {
+			t.Errorf("Expected group %v, got: %v", first.ID(), order.Group())
+		}
+	}
+}
+
+func TestNewOCO_SequentialIDsFromZero(t *testing.T) {
+	common.SetIDGenerator(common.NewSequentialIDs(0))
+	t.Cleanup(func() { common.SetIDGenerator(nil) })
+
+	first, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 90, 1)
+	second, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Sell, 110, 1)
+
+	for _, order := range events.NewOCO(*first, *second).Orders() {
+		if order.Group() == 0 {
+			t.Errorf("Expected the orders to be linked, got: %+v", order)
+		}
+	}
+}
+
+func TestOCO_ExecutionCancelsSibling(t *testing.T) {
+	simulator := marginSimulator()
+
+	buy, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 90, 1)
+	sell, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Sell, 110, 1)
+
+	if err := events.NewOCO(*buy, *sell).Occur(simulator); err != nil {
+		t.Fatalf("Error placing OCO orders: %v", err)
+	}
+
+	updateBTC(t, simulator, 100, 101, 85, 95, timeStart())
+
+	if err := simulator.CancelOrder(sell.ID()); err == nil {
+		t.Error("Expected the sibling order to be cancelled after execution")
+	}
+
+	btc := btcUSD().Base()
+	if balance := simulator.Portfolio().Balance(btc); balance != 1 {
+		t.Errorf("Expected BTC balance 1, got: %v", balance)
+	}
+}
+
+func TestBracket_ExitsActivatedByEntry(t *testing.T) {
+	simulator := marginSimulator()
+	btc := btcUSD().Base()
+
+	entry, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 100, 1)
+	stopLoss, _ := exchange.NewOrder(btcUSD(), exchange.Stop, exchange.Sell, 90, 1)
+	takeProfit, _ := exchange.NewOrder(btcUSD(), exchange.TakeProfit, exchange.Sell, 120, 1)
+
+	if err := events.NewBracket(*entry, *stopLoss, *takeProfit).Occur(simulator); err != nil {
+		t.Fatalf("Error placing bracket: %v", err)
+	}
+
+	// The stop price is reached before the entry, but exits are inactive.
+	updateBTC(t, simulator, 110, 130, 105, 110, timeStart())
+
+	if balance := simulator.Portfolio().Balance(btc); balance != 0 {
+		t.Fatalf("Exit orders executed before the entry, BTC balance: %v", balance)
+	}
+
+	updateBTC(t, simulator, 105, 106, 99, 101, timeStart().Add(time.Hour))
+
+	if balance := simulator.Portfolio().Balance(btc); balance != 1 {
+		t.Fatalf("Expected the entry order to be filled, BTC balance: %v", balance)
+	}
+
+	updateBTC(t, simulator, 101, 125, 100, 121, timeStart().Add(2*time.Hour))
+
+	if balance := simulator.Portfolio().Balance(btc); balance != 0 {
+		t.Errorf("Expected the position to be closed by take-profit, BTC balance: %v", balance)
+	}
+
+	if err := simulator.CancelOrder(stopLoss.ID()); err == nil {
+		t.Error("Expected stop-loss to be cancelled after take-profit execution")
+	}
+
+	expected := 10000.0 - 100 + 120
+	if balance := simulator.Portfolio().Balance(btcUSD().Quote()); balance != expected {
+		t.Errorf("Expected USD balance %v, got: %v", expected, balance)
+	}
+}
+
+func TestBracket_CancelEntryCancelsExits(t *testing.T) {
+	simulator := marginSimulator()
+
+	entry, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 100, 1)
+	stopLoss, _ := exchange.NewOrder(btcUSD(), exchange.Stop, exchange.Sell, 90, 1)
+	takeProfit, _ := exchange.NewOrder(btcUSD(), exchange.TakeProfit, exchange.Sell, 120, 1)
+
+	if err := events.NewBracket(*entry, *stopLoss, *takeProfit).Occur(simulator); err != nil {
+		t.Fatalf("Error placing bracket: %v", err)
+	}
+
+	if err := simulator.CancelOrder(entry.ID()); err != nil {
+		t.Fatalf("Error cancelling entry order: %v", err)
+	}
+
+	if err := simulator.CancelOrder(takeProfit.ID()); err == nil {
+		t.Error("Expected exit orders to be cancelled together with the entry")
+	}
+}
+
+func TestOCO_MarketOrderCancelsSiblings(t *testing.T) {
+	simulator := marginSimulator()
+	updateBTC(t, simulator, 100, 101, 99, 100, timeStart())
+
+	market, _ := exchange.NewOrder(btcUSD(), exchange.Market, exchange.Buy, 100, 1)
+	limit, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 90, 1)
+
+	if err := events.NewOCO(*market, *limit).Occur(simulator); err != nil {
+		t.Fatalf("Error placing OCO orders: %v", err)
+	}
+
+	updateBTC(t, simulator, 100, 101, 85, 95, timeStart().Add(time.Hour))
+
+	if status, _ := simulator.OrderStatus(limit.ID()); status != exchange.Canceled {
+		t.Errorf("Expected the limit order to be cancelled, got: %v", status)
+	}
+
+	if balance := simulator.Portfolio().Balance(btcUSD().Base()); balance != 1 {
+		t.Errorf("Expected only the market order to be executed, got balance: %v", balance)
+	}
+}
+
+func TestOCO_FailureCancelsPlacedOrders(t *testing.T) {
+	portfolio := common.NewPortfolio(btcUSD().Quote())
+	portfolio.Set(btcUSD().Quote(), 10000)
+	simulator := exchange.NewSpotSimulator(portfolio, 0)
+	updateBTC(t, &simulator, 100, 101, 99, 100, timeStart())
+
+	affordable, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 90, 1)
+	expensive, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 80, 1000)
+
+	if err := events.NewOCO(*affordable, *expensive).Occur(&simulator); err == nil {
+		t.Fatal("Expected an error placing the unaffordable order")
+	}
+
+	if status, _ := simulator.OrderStatus(affordable.ID()); status != exchange.Canceled {
+		t.Errorf("Expected the placed order to be cancelled, got: %v", status)
+	}
+}
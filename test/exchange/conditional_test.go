@@ -0,0 +1,211 @@
+package exchange_test
+
+import (
+	goErrors "errors"
+	"testing"
+	"time"
+
+	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/errors"
+	"github.com/quick-trade/xoney/exchange"
+)
+
+// updateSymbol processes a candle of the symbol with the given volume.
+func updateSymbol(
+	t *testing.T,
+	simulator exchange.Simulator,
+	symbol data.Symbol,
+	open, high, low, c, volume float64,
+	moment time.Time,
+) {
+	t.Helper()
+
+	candle := data.NewCandle(open, high, low, c, volume, moment)
+	instrument := data.NewInstrument(symbol, timeframe())
+
+	if err := simulator.UpdatePrice(*data.NewInstrumentCandle(*candle, instrument)); err != nil {
+		t.Fatalf("Error updating price: %v", err)
+	}
+}
+
+func updateBTC(t *testing.T, simulator exchange.Simulator, open, high, low, c float64, moment time.Time) {
+	t.Helper()
+
+	updateSymbol(t, simulator, btcUSD(), open, high, low, c, 0, moment)
+}
+
+func TestOrder_Triggered(t *testing.T) {
+	stopSell, _ := exchange.NewOrder(btcUSD(), exchange.Stop, exchange.Sell, 100, 1)
+	stopBuy, _ := exchange.NewOrder(btcUSD(), exchange.Stop, exchange.Buy, 100, 1)
+	takeSell, _ := exchange.NewOrder(btcUSD(), exchange.TakeProfit, exchange.Sell, 100, 1)
+	limit, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Sell, 100, 1)
+
+	if !stopSell.Triggered(110, 99) || stopSell.Triggered(110, 101) {
+		t.Error("Sell stop must be triggered only when the low reaches the stop price")
+	}
+
+	if !stopBuy.Triggered(100, 90) || stopBuy.Triggered(99, 90) {
+		t.Error("Buy stop must be triggered only when the high reaches the stop price")
+	}
+
+	if !takeSell.Triggered(101, 90) || takeSell.Triggered(99, 90) {
+		t.Error("Sell take-profit must be triggered only when the high reaches the target")
+	}
+
+	if limit.Triggered(200, 0) || limit.IsConditional() {
+		t.Error("Limit order must not be conditional")
+	}
+}
+
+func TestMarginSimulator_StopOrder_FillsAtStopPrice(t *testing.T) {
+	simulator := marginSimulator()
+	updateBTC(t, &simulator, 50000, 50000, 50000, 50000, timeStart())
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Stop, exchange.Sell, 49000, 0.1)
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Error placing stop order: %v", err)
+	}
+
+	updateBTC(t, &simulator, 49800, 50500, 49100, 49500, timeStart().Add(time.Hour))
+
+	if balance := simulator.Portfolio().Balance(btc()); balance != 0 {
+		t.Errorf("Stop order executed before reaching the stop price, BTC balance: %v", balance)
+	}
+
+	updateBTC(t, &simulator, 49500, 49600, 48000, 48500, timeStart().Add(2*time.Hour))
+
+	expected := 5000.0 + 0.1*49000
+	if balance := simulator.Portfolio().Balance(usd()); balance != expected {
+		t.Errorf("Expected USD balance %v after stop execution, got: %v", expected, balance)
+	}
+
+	if balance := simulator.Portfolio().Balance(btc()); balance != -0.1 {
+		t.Errorf("Expected BTC balance -0.1 after stop execution, got: %v", balance)
+	}
+}
+
+func TestMarginSimulator_StopOrder_GapFillsAtOpen(t *testing.T) {
+	simulator := marginSimulator()
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Stop, exchange.Buy, 50000, 0.1)
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Error placing stop order: %v", err)
+	}
+
+	updateBTC(t, &simulator, 51000, 52000, 50500, 51500, timeStart())
+
+	expected := 5000.0 - 0.1*51000
+	if balance := simulator.Portfolio().Balance(usd()); balance != expected {
+		t.Errorf("Expected gap fill at open price, USD balance %v, got: %v", expected, balance)
+	}
+}
+
+func TestMarginSimulator_TakeProfit(t *testing.T) {
+	simulator := marginSimulator()
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.TakeProfit, exchange.Sell, 52000, 0.1)
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Error placing take-profit order: %v", err)
+	}
+
+	updateBTC(t, &simulator, 50000, 51000, 49000, 50500, timeStart())
+
+	if balance := simulator.Portfolio().Balance(usd()); balance != 5000 {
+		t.Errorf("Take-profit executed before reaching the target, USD balance: %v", balance)
+	}
+
+	updateBTC(t, &simulator, 50500, 53000, 50000, 52500, timeStart().Add(time.Hour))
+
+	expected := 5000.0 + 0.1*52000
+	if balance := simulator.Portfolio().Balance(usd()); balance != expected {
+		t.Errorf("Expected USD balance %v after take-profit, got: %v", expected, balance)
+	}
+}
+
+func TestMarginSimulator_StopLimitOrder(t *testing.T) {
+	simulator := marginSimulator()
+
+	order, _ := exchange.NewStopLimitOrder(btcUSD(), exchange.Buy, 50000, 49500, 0.1)
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Error placing stop-limit order: %v", err)
+	}
+
+	// The stop is triggered, but the limit price is not reached.
+	updateBTC(t, &simulator, 49800, 50200, 49700, 50100, timeStart())
+
+	if balance := simulator.Portfolio().Balance(btc()); balance != 0 {
+		t.Errorf("Stop-limit executed above the limit price, BTC balance: %v", balance)
+	}
+
+	// The order now rests as a limit order and is filled at the limit price.
+	updateBTC(t, &simulator, 50100, 50150, 49400, 49600, timeStart().Add(time.Hour))
+
+	expected := 5000.0 - 0.1*49500
+	if balance := simulator.Portfolio().Balance(usd()); balance != expected {
+		t.Errorf("Expected USD balance %v after stop-limit execution, got: %v", expected, balance)
+	}
+}
+
+func TestNewTrailingStopOrder_InvalidOffset(t *testing.T) {
+	if _, err := exchange.NewTrailingStopOrder(btcUSD(), exchange.Sell, 100, 0, 1); err == nil {
+		t.Error("Expected error for zero trailing distance")
+	}
+
+	if _, err := exchange.NewTrailingStopPercentOrder(btcUSD(), exchange.Sell, 100, 1.5, 1); err == nil {
+		t.Error("Expected error for trailing percentage above 100%")
+	}
+}
+
+func TestNewOrder_RejectsTrailingStop(t *testing.T) {
+	_, err := exchange.NewOrder(btcUSD(), exchange.TrailingStop, exchange.Sell, 90, 1)
+
+	var constructorErr errors.OrderConstructorError
+	if !goErrors.As(err, &constructorErr) || constructorErr.Constructor != "NewTrailingStopOrder" {
+		t.Errorf("Expected OrderConstructorError pointing to NewTrailingStopOrder, got: %v", err)
+	}
+}
+
+func TestMarginSimulator_TrailingStop_Absolute(t *testing.T) {
+	simulator := marginSimulator()
+
+	order, _ := exchange.NewTrailingStopOrder(btcUSD(), exchange.Sell, 50000, 1000, 0.1)
+	if order.StopPrice() != 49000 {
+		t.Fatalf("Expected initial stop price 49000, got: %v", order.StopPrice())
+	}
+
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Error placing trailing stop order: %v", err)
+	}
+
+	// The market rises, the stop follows it up to 51000.
+	updateBTC(t, &simulator, 50000, 52000, 49500, 51800, timeStart())
+
+	if balance := simulator.Portfolio().Balance(btc()); balance != 0 {
+		t.Fatalf("Trailing stop executed too early, BTC balance: %v", balance)
+	}
+
+	// The low of the next candle crosses the moved stop price.
+	updateBTC(t, &simulator, 51800, 51900, 50500, 50700, timeStart().Add(time.Hour))
+
+	expected := 5000.0 + 0.1*51000
+	if balance := simulator.Portfolio().Balance(usd()); balance != expected {
+		t.Errorf("Expected USD balance %v after trailing stop execution, got: %v", expected, balance)
+	}
+}
+
+func TestMarginSimulator_TrailingStop_Percent(t *testing.T) {
+	simulator := marginSimulator()
+
+	order, _ := exchange.NewTrailingStopPercentOrder(btcUSD(), exchange.Buy, 100, 0.1, 1)
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Error placing trailing stop order: %v", err)
+	}
+
+	// The market falls to 80 and closes above the new stop price 88.
+	updateBTC(t, &simulator, 100, 101, 80, 90, timeStart())
+
+	expected := 5000.0 - 88
+	if balance := simulator.Portfolio().Balance(usd()); balance != expected {
+		t.Errorf("Expected USD balance %v after trailing stop execution, got: %v", expected, balance)
+	}
+}
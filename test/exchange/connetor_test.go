@@ -44,8 +44,8 @@ func portfolioUSD() common.Portfolio {
 	return portfolio
 }
 
-func marginSimulator() exchange.MarginSimulator {
-	return exchange.NewMarginSimulator(portfolioUSD(), 0)
+func marginSimulator(options ...exchange.SimulatorOption) exchange.MarginSimulator {
+	return exchange.NewMarginSimulator(portfolioUSD(), 0, options...)
 }
 
 func spotSimulator() exchange.SpotSimulator {
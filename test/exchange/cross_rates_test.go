@@ -0,0 +1,72 @@
+package exchange_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/exchange"
+)
+
+func ethCurrency() data.Currency {
+	return data.NewCurrency("ETH", "BINANCE")
+}
+
+func ethBTC() data.Symbol {
+	return *data.NewSymbolFromCurrencies(ethCurrency(), btc())
+}
+
+func TestMarginSimulator_CrossRateValuation(t *testing.T) {
+	simulator := marginSimulator()
+
+	updateBTC(t, &simulator, 50000, 50000, 50000, 50000, timeStart())
+	updateSymbol(t, &simulator, ethBTC(), 0.05, 0.05, 0.05, 0.05, 0, timeStart())
+
+	order, _ := exchange.NewOrder(ethBTC(), exchange.Market, exchange.Buy, 0.05, 1)
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Error placing order: %v", err)
+	}
+
+	total, err := simulator.Total()
+	if err != nil {
+		t.Fatalf("Expected ETH to be valued through BTC, got: %v", err)
+	}
+
+	if math.Abs(total-5000) > 1e-9 {
+		t.Errorf("Expected total 5000, got: %v", total)
+	}
+
+	updateSymbol(t, &simulator, ethBTC(), 0.06, 0.06, 0.06, 0.06, 0, timeStart())
+
+	if total, _ := simulator.Total(); math.Abs(total-5500) > 1e-9 {
+		t.Errorf("Expected total 5500 after ETH rally, got: %v", total)
+	}
+}
+
+func TestMarginSimulator_GetPricesCrossRate(t *testing.T) {
+	simulator := marginSimulator()
+
+	updateBTC(t, &simulator, 50000, 50000, 50000, 50000, timeStart())
+	updateSymbol(t, &simulator, ethBTC(), 0.05, 0.05, 0.05, 0.05, 0, timeStart())
+
+	ethUSD := *data.NewSymbolFromCurrencies(ethCurrency(), usd())
+	prices, errs := simulator.GetPrices([]data.Symbol{btcUSD(), ethUSD})
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	expected := map[data.Symbol]float64{btcUSD(): 50000, ethUSD: 2500}
+	for price := range prices {
+		if math.Abs(price.Price-expected[price.Symbol]) > 1e-9 {
+			t.Errorf("Unexpected price of %v: %v", price.Symbol, price.Price)
+		}
+	}
+
+	unknown := *data.NewSymbolFromCurrencies(data.NewCurrency("SOL", "BINANCE"), usd())
+	if _, errs := simulator.GetPrices([]data.Symbol{unknown}); <-errs == nil {
+		t.Error("Expected error for a symbol without price")
+	}
+}
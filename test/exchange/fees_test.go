@@ -0,0 +1,174 @@
+package exchange_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/exchange"
+)
+
+func bnb() data.Currency {
+	return data.NewCurrency("BNB", "BINANCE")
+}
+
+func tieredFees(t *testing.T) *exchange.FeeSchedule {
+	t.Helper()
+
+	schedule, err := exchange.NewFeeSchedule(
+		exchange.FeeTier{MinVolume: 10000, Maker: -0.0001, Taker: 0.0005},
+		exchange.FeeTier{MinVolume: 0, Maker: 0.001, Taker: 0.002},
+	)
+	if err != nil {
+		t.Fatalf("Error creating fee schedule: %v", err)
+	}
+
+	return schedule
+}
+
+func TestNewFeeSchedule_NoTiers(t *testing.T) {
+	if _, err := exchange.NewFeeSchedule(); err == nil {
+		t.Error("Expected error for a fee schedule without tiers")
+	}
+}
+
+func TestMarginSimulator_MakerAndTakerFees(t *testing.T) {
+	simulator := exchange.NewMarginSimulator(portfolioUSD(), 0, exchange.WithFeeSchedule(tieredFees(t)))
+	updateBTC(t, &simulator, 100, 100, 100, 100, timeStart())
+
+	market, _ := exchange.NewOrder(btcUSD(), exchange.Market, exchange.Buy, 100, 1)
+	if err := simulator.PlaceOrder(*market); err != nil {
+		t.Fatalf("Error placing market order: %v", err)
+	}
+
+	expected := 5000 - 100 - 100*0.002
+	if balance := simulator.Portfolio().Balance(usd()); math.Abs(balance-expected) > epsilon {
+		t.Fatalf("Expected taker fee to be charged, USD balance %v, got: %v", expected, balance)
+	}
+
+	limit, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Sell, 110, 1)
+	if err := simulator.PlaceOrder(*limit); err != nil {
+		t.Fatalf("Error placing limit order: %v", err)
+	}
+
+	updateBTC(t, &simulator, 100, 115, 95, 110, timeStart().Add(time.Hour))
+
+	expected += 110 - 110*0.001
+	if balance := simulator.Portfolio().Balance(usd()); math.Abs(balance-expected) > epsilon {
+		t.Errorf("Expected maker fee to be charged, USD balance %v, got: %v", expected, balance)
+	}
+}
+
+func TestMarginSimulator_FeeTiersByRollingVolume(t *testing.T) {
+	portfolio := portfolioUSD()
+	portfolio.Set(usd(), 100000)
+
+	schedule := tieredFees(t)
+	schedule.SetWindow(24 * time.Hour)
+
+	simulator := exchange.NewMarginSimulator(portfolio, 0, exchange.WithFeeSchedule(schedule))
+	updateBTC(t, &simulator, 100, 100, 100, 100, timeStart())
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Market, exchange.Buy, 100, 100)
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Error placing market order: %v", err)
+	}
+
+	if rate := schedule.Rate(exchange.Maker); rate != -0.0001 {
+		t.Fatalf("Expected the maker rebate tier after trading 10000, got rate: %v", rate)
+	}
+
+	updateBTC(t, &simulator, 100, 100, 100, 100, timeStart().Add(48*time.Hour))
+
+	order, _ = exchange.NewOrder(btcUSD(), exchange.Market, exchange.Sell, 100, 1)
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Error placing market order: %v", err)
+	}
+
+	if volume := schedule.Volume(); volume != 100 {
+		t.Errorf("Expected old volume to leave the rolling window, got: %v", volume)
+	}
+
+	if rate := schedule.Rate(exchange.Taker); rate != 0.002 {
+		t.Errorf("Expected the base tier after the window has passed, got rate: %v", rate)
+	}
+}
+
+func TestMarginSimulator_LapsedFeeTier(t *testing.T) {
+	portfolio := portfolioUSD()
+	portfolio.Set(usd(), 100000)
+
+	schedule := tieredFees(t)
+	schedule.SetWindow(24 * time.Hour)
+
+	simulator := exchange.NewMarginSimulator(portfolio, 0, exchange.WithFeeSchedule(schedule))
+	updateBTC(t, &simulator, 100, 100, 100, 100, timeStart())
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Market, exchange.Buy, 100, 100)
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Error placing market order: %v", err)
+	}
+
+	updateBTC(t, &simulator, 100, 100, 100, 100, timeStart().Add(48*time.Hour))
+
+	if volume := schedule.Volume(); volume != 0 {
+		t.Errorf("Expected the volume to leave the window without trading, got: %v", volume)
+	}
+
+	order, _ = exchange.NewOrder(btcUSD(), exchange.Market, exchange.Sell, 100, 1)
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Error placing market order: %v", err)
+	}
+
+	fills := simulator.Fills()
+	if fee := fills[len(fills)-1].Fee; math.Abs(fee-0.2) > epsilon {
+		t.Errorf("Expected the fill to be charged by the base tier, got fee: %v", fee)
+	}
+}
+
+func TestMarginSimulator_FeesInBase(t *testing.T) {
+	schedule := exchange.NewFlatFeeSchedule(0.001, 0.001)
+	schedule.ChargeInBase()
+
+	simulator := exchange.NewMarginSimulator(portfolioUSD(), 0, exchange.WithFeeSchedule(schedule))
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Market, exchange.Buy, 100, 2)
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Error placing market order: %v", err)
+	}
+
+	if balance := simulator.Portfolio().Balance(btc()); math.Abs(balance-1.998) > epsilon {
+		t.Errorf("Expected fee to be charged in BTC, BTC balance: %v", balance)
+	}
+
+	if balance := simulator.Portfolio().Balance(usd()); balance != 4800 {
+		t.Errorf("Expected no fee in USD, USD balance: %v", balance)
+	}
+}
+
+func TestMarginSimulator_FeesInToken(t *testing.T) {
+	schedule := exchange.NewFlatFeeSchedule(0.001, 0.001)
+	schedule.ChargeInToken(bnb(), 0.25)
+
+	portfolio := portfolioUSD()
+	portfolio.Set(bnb(), 10)
+
+	simulator := exchange.NewMarginSimulator(portfolio, 0, exchange.WithFeeSchedule(schedule))
+
+	updateSymbol(t, &simulator, *data.NewSymbol("BNB", "USD", "BINANCE"), 300, 300, 300, 300, 0, timeStart())
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Market, exchange.Buy, 1000, 2)
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Error placing market order: %v", err)
+	}
+
+	// Fee: 2000 * 0.001 = 2 USD, with 25% discount 1.5 USD = 0.005 BNB.
+	if balance := simulator.Portfolio().Balance(bnb()); math.Abs(balance-9.995) > epsilon {
+		t.Errorf("Expected fee to be charged in BNB, BNB balance: %v", balance)
+	}
+
+	if cost := simulator.Costs()[exchange.CommissionCost]; math.Abs(cost-1.5) > epsilon {
+		t.Errorf("Expected commission cost 1.5, got: %v", cost)
+	}
+}
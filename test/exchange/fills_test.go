@@ -0,0 +1,59 @@
+package exchange_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/quick-trade/xoney/exchange"
+)
+
+func TestMarginSimulator_Fills(t *testing.T) {
+	simulator := exchange.NewMarginSimulator(portfolioUSD(), 0.001)
+	updateBTC(t, &simulator, 100, 100, 100, 100, timeStart())
+
+	market, _ := exchange.NewOrder(btcUSD(), exchange.Market, exchange.Buy, 100, 2)
+	limit, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Sell, 120, 2)
+
+	for _, order := range []*exchange.Order{market, limit} {
+		if err := simulator.PlaceOrder(*order); err != nil {
+			t.Fatalf("Error placing order: %v", err)
+		}
+	}
+
+	updateBTC(t, &simulator, 100, 125, 95, 110, timeStart().Add(time.Hour))
+
+	fills := simulator.Fills()
+	if len(fills) != 2 {
+		t.Fatalf("Expected 2 fills, got: %v", fills)
+	}
+
+	first, second := fills[0], fills[1]
+
+	if first.OrderID != market.ID() || first.IsMaker() || first.Side != exchange.Buy {
+		t.Errorf("Unexpected market order fill: %+v", first)
+	}
+
+	if first.Price != 100 || first.Amount != 2 || first.Fee != 0.2 || first.FeeCurrency != usd() {
+		t.Errorf("Unexpected market order execution: %+v", first)
+	}
+
+	if !first.Time.Equal(timeStart()) {
+		t.Errorf("Expected market order fill time %v, got: %v", timeStart(), first.Time)
+	}
+
+	if second.OrderID != limit.ID() || !second.IsMaker() || second.Notional() != 240 {
+		t.Errorf("Unexpected limit order fill: %+v", second)
+	}
+
+	if !second.Time.Equal(timeStart().Add(time.Hour)) {
+		t.Errorf("Expected limit order fill at candle close, got: %v", second.Time)
+	}
+
+	if err := simulator.Cleanup(); err != nil {
+		t.Fatalf("Error during cleanup: %v", err)
+	}
+
+	if fills := simulator.Fills(); len(fills) != 0 {
+		t.Errorf("Expected empty journal after cleanup, got: %v", fills)
+	}
+}
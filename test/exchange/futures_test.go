@@ -0,0 +1,232 @@
+package exchange_test
+
+import (
+	goErrors "errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/quick-trade/xoney/errors"
+	"github.com/quick-trade/xoney/exchange"
+)
+
+func futuresSimulator(t *testing.T, options ...exchange.FuturesOption) *exchange.FuturesSimulator {
+	t.Helper()
+
+	simulator := exchange.NewFuturesSimulator(portfolioUSD(), 0, options...)
+	updateBTC(t, simulator, 50000, 50000, 50000, 50000, timeStart())
+
+	return simulator
+}
+
+func trade(t *testing.T, simulator exchange.Simulator, side exchange.OrderSide, price, amount float64) {
+	t.Helper()
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Market, side, price, amount)
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Error placing order: %v", err)
+	}
+}
+
+func TestFuturesSimulator_PositionAndUnrealizedPnL(t *testing.T) {
+	simulator := futuresSimulator(t, exchange.WithLeverage(5))
+
+	trade(t, simulator, exchange.Buy, 50000, 0.4)
+	updateBTC(t, simulator, 50000, 51500, 49500, 51000, timeStart().Add(time.Hour))
+
+	position := simulator.Position(btcUSD())
+	if position.Size != 0.4 || position.EntryPrice != 50000 || position.MarkPrice != 51000 {
+		t.Fatalf("Unexpected position: %+v", position)
+	}
+
+	if pnl := position.UnrealizedPnL(); math.Abs(pnl-400) > epsilon {
+		t.Errorf("Expected unrealized PnL 400, got: %v", pnl)
+	}
+
+	if balance := simulator.Portfolio().Balance(btc()); balance != 0 {
+		t.Errorf("Futures must not hold the base currency, BTC balance: %v", balance)
+	}
+
+	if total, _ := simulator.Total(); math.Abs(total-5400) > epsilon {
+		t.Errorf("Expected equity 5400, got: %v", total)
+	}
+}
+
+func TestFuturesSimulator_RejectsOrderExceedingAvailableMargin(t *testing.T) {
+	simulator := futuresSimulator(t, exchange.WithLeverage(5))
+	trade(t, simulator, exchange.Buy, 50000, 0.4)
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Market, exchange.Buy, 50000, 0.2)
+
+	var marginErr errors.InsufficientMarginError
+	if err := simulator.PlaceOrder(*order); !goErrors.As(err, &marginErr) {
+		t.Fatalf("Expected InsufficientMarginError, got: %v", err)
+	}
+
+	if marginErr.Required != 2000 || marginErr.Available != 1000 {
+		t.Errorf("Unexpected margin error: %+v", marginErr)
+	}
+
+	// Flipping the position requires the margin only for the new side.
+	trade(t, simulator, exchange.Sell, 50000, 0.5)
+
+	if size := simulator.Position(btcUSD()).Size; math.Abs(size+0.1) > epsilon {
+		t.Errorf("Expected short position of 0.1, got: %v", size)
+	}
+}
+
+func TestFuturesSimulator_AveragingAndRealizedPnL(t *testing.T) {
+	simulator := futuresSimulator(t, exchange.WithLeverage(10))
+
+	trade(t, simulator, exchange.Buy, 50000, 0.1)
+	trade(t, simulator, exchange.Buy, 52000, 0.1)
+
+	if entry := simulator.Position(btcUSD()).EntryPrice; math.Abs(entry-51000) > epsilon {
+		t.Fatalf("Expected average entry price 51000, got: %v", entry)
+	}
+
+	trade(t, simulator, exchange.Sell, 53000, 0.1)
+
+	if balance := simulator.Portfolio().Balance(usd()); math.Abs(balance-5200) > epsilon {
+		t.Errorf("Expected realized profit of 200, USD balance: %v", balance)
+	}
+
+	trade(t, simulator, exchange.Sell, 50000, 0.3)
+
+	position := simulator.Position(btcUSD())
+	if math.Abs(position.Size+0.2) > epsilon || position.EntryPrice != 50000 {
+		t.Errorf("Expected flipped short position, got: %+v", position)
+	}
+
+	if balance := simulator.Portfolio().Balance(usd()); math.Abs(balance-5100) > epsilon {
+		t.Errorf("Expected realized loss of 100, USD balance: %v", balance)
+	}
+}
+
+func TestFuturesSimulator_Funding(t *testing.T) {
+	rates, err := exchange.NewFundingRates(
+		exchange.DefaultFundingInterval,
+		exchange.FundingRate{Time: timeStart(), Rate: 0.0001},
+		exchange.FundingRate{Time: timeStart().Add(12 * time.Hour), Rate: -0.0002},
+	)
+	if err != nil {
+		t.Fatalf("Error creating funding rates: %v", err)
+	}
+
+	simulator := futuresSimulator(t, exchange.WithFundingRates(btcUSD(), rates))
+	trade(t, simulator, exchange.Buy, 50000, 0.1)
+
+	updateBTC(t, simulator, 50000, 50000, 50000, 50000, timeStart().Add(7*time.Hour))
+
+	if cost := simulator.Costs()[exchange.FundingCost]; cost != 0 {
+		t.Fatalf("Funding settled before the interval: %v", cost)
+	}
+
+	updateBTC(t, simulator, 50000, 50000, 50000, 50000, timeStart().Add(8*time.Hour))
+
+	if balance := simulator.Portfolio().Balance(usd()); math.Abs(balance-4999.5) > epsilon {
+		t.Fatalf("Expected long position to pay the funding, USD balance: %v", balance)
+	}
+
+	updateBTC(t, simulator, 50000, 50000, 50000, 50000, timeStart().Add(24*time.Hour))
+
+	if balance := simulator.Portfolio().Balance(usd()); math.Abs(balance-5001.5) > epsilon {
+		t.Errorf("Expected long position to receive negative funding twice, USD balance: %v", balance)
+	}
+
+	if cost := simulator.Costs()[exchange.FundingCost]; math.Abs(cost+1.5) > epsilon {
+		t.Errorf("Expected net funding cost -1.5, got: %v", cost)
+	}
+}
+
+func TestFuturesSimulator_IsolatedLiquidation(t *testing.T) {
+	simulator := futuresSimulator(t,
+		exchange.WithLeverage(10),
+		exchange.WithMarginMode(exchange.IsolatedMargin),
+		exchange.WithMaintenanceMargin(0.005, 0.001),
+	)
+
+	trade(t, simulator, exchange.Buy, 50000, 0.5)
+
+	if balance := simulator.Portfolio().Balance(usd()); balance != 2500 {
+		t.Fatalf("Expected isolated margin to be allocated, USD balance: %v", balance)
+	}
+
+	if margin := simulator.Position(btcUSD()).Margin; margin != 2500 {
+		t.Fatalf("Expected position margin 2500, got: %v", margin)
+	}
+
+	updateBTC(t, simulator, 48000, 48500, 44000, 46000, timeStart().Add(time.Hour))
+
+	price := 22500 / 0.4975
+	fee := 0.001 * 0.5 * price
+	expected := 5000 + 0.5*(price-50000) - fee
+
+	if size := simulator.Position(btcUSD()).Size; size != 0 {
+		t.Errorf("Expected position to be liquidated, size: %v", size)
+	}
+
+	if balance := simulator.Portfolio().Balance(usd()); math.Abs(balance-expected) > 1e-6 {
+		t.Errorf("Expected USD balance %v after liquidation, got: %v", expected, balance)
+	}
+
+	if balance := simulator.Portfolio().Balance(usd()); balance < 2500 {
+		t.Errorf("Isolated loss must be limited by the position margin, USD balance: %v", balance)
+	}
+}
+
+func TestFuturesSimulator_CrossLiquidation(t *testing.T) {
+	simulator := futuresSimulator(t, exchange.WithLeverage(10))
+	trade(t, simulator, exchange.Buy, 50000, 0.5)
+
+	updateBTC(t, simulator, 45000, 46000, 41000, 43000, timeStart().Add(time.Hour))
+
+	if size := simulator.Position(btcUSD()).Size; size != 0.5 {
+		t.Fatalf("Position must not be liquidated above maintenance, size: %v", size)
+	}
+
+	updateBTC(t, simulator, 43000, 43500, 39000, 40000, timeStart().Add(2*time.Hour))
+
+	fills := simulator.Fills()
+	last := fills[len(fills)-1]
+
+	if price := 20000 / 0.4975; math.Abs(last.Price-price) > 1e-6 || last.Side != exchange.Sell {
+		t.Errorf("Expected liquidation at %v, got: %+v", price, last)
+	}
+
+	if len(simulator.Positions()) != 0 {
+		t.Errorf("Expected all positions to be closed, got: %v", simulator.Positions())
+	}
+}
+
+func TestFuturesSimulator_StopAboveLiquidationPrice(t *testing.T) {
+	simulator := futuresSimulator(t, exchange.WithLeverage(10))
+	trade(t, simulator, exchange.Buy, 50000, 0.5)
+
+	stop, _ := exchange.NewOrder(btcUSD(), exchange.Stop, exchange.Sell, 42000, 0.5)
+	if err := simulator.PlaceOrder(*stop); err != nil {
+		t.Fatalf("Error placing stop order: %v", err)
+	}
+
+	// The liquidation price is 40201, the stop is triggered before it is reached.
+	updateBTC(t, simulator, 45000, 46000, 39000, 40000, timeStart().Add(time.Hour))
+
+	if status, _ := simulator.OrderStatus(stop.ID()); status != exchange.Filled {
+		t.Errorf("Expected the stop to be filled, got: %v", status)
+	}
+
+	fills := simulator.Fills()
+	if last := fills[len(fills)-1]; last.Price != 42000 || last.Side != exchange.Sell {
+		t.Errorf("Expected the position to be closed by the stop, got: %+v", last)
+	}
+
+	if cost := simulator.Costs()[exchange.LiquidationCost]; cost != 0 {
+		t.Errorf("Expected no liquidation, got the cost: %v", cost)
+	}
+}
+
+func TestNewFundingRates_InvalidInterval(t *testing.T) {
+	if _, err := exchange.NewFundingRates(0); err == nil {
+		t.Error("Expected error for zero funding interval")
+	}
+}
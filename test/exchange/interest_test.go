@@ -0,0 +1,70 @@
+package exchange_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/exchange"
+)
+
+const day = 24 * time.Hour
+
+func TestMarginSimulator_ShortFeeAccrual(t *testing.T) {
+	simulator := marginSimulator(exchange.WithBorrowRates(map[data.Currency]float64{btc(): 0.1}))
+	updateBTC(t, &simulator, 50000, 50000, 50000, 50000, timeStart())
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Market, exchange.Sell, 50000, 0.1)
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Error opening short position: %v", err)
+	}
+
+	updateBTC(t, &simulator, 50000, 50000, 50000, 50000, timeStart().Add(73*day))
+
+	if balance := simulator.Portfolio().Balance(btc()); math.Abs(balance+0.102) > epsilon {
+		t.Errorf("Expected borrowed BTC to grow by the interest, got: %v", balance)
+	}
+
+	if cost := simulator.Costs()[exchange.InterestCost]; math.Abs(cost-100) > 1e-6 {
+		t.Errorf("Expected interest cost 100, got: %v", cost)
+	}
+}
+
+func TestMarginSimulator_LeverageInterestAccrual(t *testing.T) {
+	simulator := marginSimulator(exchange.WithBorrowRates(map[data.Currency]float64{usd(): 0.05, btc(): 0.1}))
+	updateBTC(t, &simulator, 50000, 50000, 50000, 50000, timeStart())
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Market, exchange.Buy, 50000, 0.2)
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Error opening leveraged position: %v", err)
+	}
+
+	// Several updates at the same moment must not accrue anything.
+	updateBTC(t, &simulator, 50000, 50000, 50000, 50000, timeStart())
+
+	updateBTC(t, &simulator, 50000, 50000, 50000, 50000, timeStart().Add(365*day/2))
+
+	if balance := simulator.Portfolio().Balance(usd()); math.Abs(balance+5125) > 1e-6 {
+		t.Errorf("Expected USD debt with the half-year interest, got: %v", balance)
+	}
+
+	if balance := simulator.Portfolio().Balance(btc()); balance != 0.2 {
+		t.Errorf("Long BTC position must not accrue interest, got: %v", balance)
+	}
+}
+
+func TestMarginSimulator_NoInterestWithoutBorrowing(t *testing.T) {
+	simulator := marginSimulator(exchange.WithBorrowRates(map[data.Currency]float64{usd(): 0.05}))
+	updateBTC(t, &simulator, 50000, 50000, 50000, 50000, timeStart())
+
+	updateBTC(t, &simulator, 50000, 50000, 50000, 50000, timeStart().Add(365*day))
+
+	if balance := simulator.Portfolio().Balance(usd()); balance != 5000 {
+		t.Errorf("Expected positive balance to stay unchanged, got: %v", balance)
+	}
+
+	if cost := simulator.Costs()[exchange.InterestCost]; cost != 0 {
+		t.Errorf("Expected no interest cost, got: %v", cost)
+	}
+}
@@ -0,0 +1,119 @@
+package exchange_test
+
+import (
+	"testing"
+
+	"github.com/quick-trade/xoney/exchange"
+)
+
+func placeAll(t *testing.T, simulator exchange.Simulator, orders ...exchange.Order) {
+	t.Helper()
+
+	for _, order := range orders {
+		if err := simulator.PlaceOrder(order); err != nil {
+			t.Fatalf("Error placing order: %v", err)
+		}
+	}
+}
+
+func filledSides(simulator *exchange.MarginSimulator) []exchange.OrderSide {
+	sides := make([]exchange.OrderSide, 0)
+	for _, fill := range simulator.Fills() {
+		sides = append(sides, fill.Side)
+	}
+
+	return sides
+}
+
+func TestIntrabarPath_OCOResolvedByPath(t *testing.T) {
+	tests := []struct {
+		path     exchange.IntrabarPath
+		expected exchange.OrderSide
+	}{
+		{exchange.PathOHLC, exchange.Sell},
+		{exchange.PathOLHC, exchange.Buy},
+		{exchange.PathNearestExtreme, exchange.Sell},
+	}
+
+	for _, test := range tests {
+		simulator := marginSimulator(exchange.WithIntrabarPath(test.path))
+
+		buy, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 49000, 0.1)
+		sell, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Sell, 51000, 0.1)
+		placeAll(t, &simulator, exchange.LinkOCO(*buy, *sell)...)
+
+		updateBTC(t, &simulator, 50000, 51000, 48000, 50000, timeStart())
+
+		sides := filledSides(&simulator)
+		if len(sides) != 1 || sides[0] != test.expected {
+			t.Errorf("%s: expected only the %s leg to be filled, got: %v", test.path, test.expected, sides)
+		}
+	}
+}
+
+func TestIntrabarPath_FillSequence(t *testing.T) {
+	simulator := marginSimulator(exchange.WithIntrabarPath(exchange.PathOLHC))
+
+	sell, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Sell, 50500, 0.1)
+	buy, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 49500, 0.1)
+	placeAll(t, &simulator, *sell, *buy)
+
+	updateBTC(t, &simulator, 50000, 51000, 49000, 50000, timeStart())
+
+	sides := filledSides(&simulator)
+	if len(sides) != 2 || sides[0] != exchange.Buy || sides[1] != exchange.Sell {
+		t.Errorf("Expected the buy limit to be filled before the sell limit, got: %v", sides)
+	}
+}
+
+func TestIntrabarPath_PessimisticStopFirst(t *testing.T) {
+	for _, test := range []struct {
+		path     exchange.IntrabarPath
+		expected exchange.OrderType
+	}{
+		{exchange.PathNearestExtreme, exchange.TakeProfit},
+		{exchange.PathPessimistic, exchange.Stop},
+	} {
+		simulator := marginSimulator(exchange.WithIntrabarPath(test.path))
+
+		stop, _ := exchange.NewOrder(btcUSD(), exchange.Stop, exchange.Sell, 49000, 0.1)
+		target, _ := exchange.NewOrder(btcUSD(), exchange.TakeProfit, exchange.Sell, 51000, 0.1)
+		placeAll(t, &simulator, exchange.LinkOCO(*target, *stop)...)
+
+		updateBTC(t, &simulator, 50000, 51500, 48500, 50000, timeStart())
+
+		updates := drainUpdates(&simulator)
+		for _, update := range updates {
+			if update.Status == exchange.Filled && update.Order.Type() != test.expected {
+				t.Errorf("%s: expected %s to be filled, got: %s", test.path, test.expected, update.Order.Type())
+			}
+		}
+
+		if len(simulator.Fills()) != 1 {
+			t.Errorf("%s: expected exactly one leg to be filled, got: %v", test.path, simulator.Fills())
+		}
+	}
+}
+
+func TestIntrabarPath_StopLimitAfterTrigger(t *testing.T) {
+	tests := []struct {
+		path   exchange.IntrabarPath
+		filled bool
+	}{
+		{exchange.PathOHLC, true},
+		{exchange.PathOLHC, false},
+	}
+
+	for _, test := range tests {
+		simulator := marginSimulator(exchange.WithIntrabarPath(test.path))
+
+		order, _ := exchange.NewStopLimitOrder(btcUSD(), exchange.Buy, 51000, 50500, 0.1)
+		placeAll(t, &simulator, *order)
+
+		updateBTC(t, &simulator, 50000, 51000, 49000, 51000, timeStart())
+
+		if filled := len(simulator.Fills()) == 1; filled != test.filled {
+			t.Errorf("%s: expected filled=%v, got fills: %v", test.path, test.filled, simulator.Fills())
+		}
+	}
+}
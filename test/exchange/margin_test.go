@@ -0,0 +1,173 @@
+package exchange_test
+
+import (
+	goErrors "errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/quick-trade/xoney/errors"
+	"github.com/quick-trade/xoney/exchange"
+)
+
+func openPosition(t *testing.T, simulator *exchange.MarginSimulator, side exchange.OrderSide, amount float64) {
+	t.Helper()
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Market, side, 50000, amount)
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Error opening position: %v", err)
+	}
+}
+
+func TestMarginSimulator_RejectsOrderExceedingBuyingPower(t *testing.T) {
+	simulator := marginSimulator(exchange.WithMargin(0.5, 0.25, 0.01))
+	updateBTC(t, &simulator, 50000, 50000, 50000, 50000, timeStart())
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Market, exchange.Buy, 50000, 0.3)
+
+	err := simulator.PlaceOrder(*order)
+
+	var marginErr errors.InsufficientMarginError
+	if !goErrors.As(err, &marginErr) {
+		t.Fatalf("Expected InsufficientMarginError, got: %v", err)
+	}
+
+	if marginErr.Required != 7500 || marginErr.Available != 5000 {
+		t.Errorf("Unexpected margin error: %+v", marginErr)
+	}
+
+	if status, _ := simulator.OrderStatus(order.ID()); status != exchange.Rejected {
+		t.Errorf("Expected the order to be rejected, got: %v", status)
+	}
+
+	if balance := simulator.Portfolio().Balance(btc()); balance != 0 {
+		t.Errorf("Rejected order must not be executed, BTC balance: %v", balance)
+	}
+}
+
+func TestMarginSimulator_AllowsLeverageWithinInitialMargin(t *testing.T) {
+	simulator := marginSimulator(exchange.WithMargin(0.5, 0.25, 0.01))
+	updateBTC(t, &simulator, 50000, 50000, 50000, 50000, timeStart())
+
+	openPosition(t, &simulator, exchange.Buy, 0.2)
+
+	if balance := simulator.Portfolio().Balance(usd()); balance != -5000 {
+		t.Fatalf("Expected borrowed USD balance -5000, got: %v", balance)
+	}
+
+	// Reducing the exposure is allowed regardless of the margin.
+	updateBTC(t, &simulator, 45000, 45000, 45000, 45000, timeStart().Add(time.Hour))
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Market, exchange.Sell, 45000, 0.1)
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Errorf("Expected reducing order to be accepted, got: %v", err)
+	}
+}
+
+func TestMarginSimulator_LiquidatesLongIntrabar(t *testing.T) {
+	simulator := marginSimulator(exchange.WithMargin(0.5, 0.25, 0.01))
+	updateBTC(t, &simulator, 50000, 50000, 50000, 50000, timeStart())
+	openPosition(t, &simulator, exchange.Buy, 0.2)
+	drainUpdates(&simulator)
+
+	stop, _ := exchange.NewOrder(btcUSD(), exchange.Stop, exchange.Sell, 20000, 0.2)
+	if err := simulator.PlaceOrder(*stop); err != nil {
+		t.Fatalf("Error placing stop order: %v", err)
+	}
+
+	updateBTC(t, &simulator, 45000, 46000, 30000, 44000, timeStart().Add(time.Hour))
+
+	price := 5000 / 0.15
+	fee := 0.01 * 0.2 * price
+
+	portfolio := simulator.Portfolio()
+	if balance := portfolio.Balance(btc()); balance != 0 {
+		t.Errorf("Expected position to be closed, BTC balance: %v", balance)
+	}
+
+	if balance := portfolio.Balance(usd()); math.Abs(balance-(0.2*price-5000-fee)) > 1e-6 {
+		t.Errorf("Unexpected USD balance after liquidation: %v", balance)
+	}
+
+	if cost := simulator.Costs()[exchange.LiquidationCost]; math.Abs(cost-fee) > 1e-6 {
+		t.Errorf("Expected liquidation cost %v, got: %v", fee, cost)
+	}
+
+	updates := drainUpdates(&simulator)
+	if len(updates) != 2 || updates[0].Status != exchange.Canceled || updates[1].Status != exchange.Filled {
+		t.Fatalf("Expected cancellation and liquidation to be reported, got: %v", updates)
+	}
+
+	if math.Abs(updates[1].Order.Price()-price) > 1e-6 {
+		t.Errorf("Expected liquidation at %v, got: %v", price, updates[1].Order.Price())
+	}
+
+	if len(simulator.OpenOrders()) != 0 {
+		t.Error("Expected open orders to be cancelled on liquidation")
+	}
+}
+
+func TestMarginSimulator_LiquidationAtGapOpen(t *testing.T) {
+	simulator := marginSimulator(exchange.WithMargin(0.5, 0.25, 0.01))
+	updateBTC(t, &simulator, 50000, 50000, 50000, 50000, timeStart())
+	openPosition(t, &simulator, exchange.Buy, 0.2)
+
+	updateBTC(t, &simulator, 32000, 33000, 30000, 31000, timeStart().Add(time.Hour))
+
+	fills := simulator.Fills()
+	if last := fills[len(fills)-1]; last.Price != 32000 || last.Side != exchange.Sell {
+		t.Errorf("Expected liquidation at the open, got: %+v", last)
+	}
+}
+
+func TestMarginSimulator_LiquidatesShortAtHigh(t *testing.T) {
+	simulator := marginSimulator(exchange.WithMargin(0.5, 0.25, 0.01))
+	updateBTC(t, &simulator, 50000, 50000, 50000, 50000, timeStart())
+	openPosition(t, &simulator, exchange.Sell, 0.2)
+
+	updateBTC(t, &simulator, 52000, 59000, 51000, 55000, timeStart().Add(time.Hour))
+
+	if balance := simulator.Portfolio().Balance(btc()); balance != -0.2 {
+		t.Fatalf("Position must not be liquidated above maintenance, BTC balance: %v", balance)
+	}
+
+	updateBTC(t, &simulator, 55000, 65000, 54000, 58000, timeStart().Add(2*time.Hour))
+
+	fills := simulator.Fills()
+	if last := fills[len(fills)-1]; math.Abs(last.Price-60000) > 1e-6 || last.Side != exchange.Buy {
+		t.Errorf("Expected short liquidation at 60000, got: %+v", last)
+	}
+
+	if balance := simulator.Portfolio().Balance(btc()); balance != 0 {
+		t.Errorf("Expected short position to be closed, BTC balance: %v", balance)
+	}
+}
+
+func TestMarginSimulator_StopAboveLiquidationPrice(t *testing.T) {
+	simulator := marginSimulator(exchange.WithMargin(0.2, 0.1, 0.01))
+	updateBTC(t, &simulator, 100, 100, 100, 100, timeStart())
+
+	buy, _ := exchange.NewOrder(btcUSD(), exchange.Market, exchange.Buy, 100, 200)
+	stop, _ := exchange.NewOrder(btcUSD(), exchange.Stop, exchange.Sell, 95, 200)
+
+	for _, order := range []*exchange.Order{buy, stop} {
+		if err := simulator.PlaceOrder(*order); err != nil {
+			t.Fatalf("Error placing order: %v", err)
+		}
+	}
+
+	// The liquidation price is 83.33, the stop is triggered before it is reached.
+	updateBTC(t, &simulator, 100, 100, 80, 82, timeStart().Add(time.Hour))
+
+	if status, _ := simulator.OrderStatus(stop.ID()); status != exchange.Filled {
+		t.Errorf("Expected the stop to be filled, got: %v", status)
+	}
+
+	if cost := simulator.Costs()[exchange.LiquidationCost]; cost != 0 {
+		t.Errorf("Expected no liquidation, got the cost: %v", cost)
+	}
+
+	if balance := simulator.Portfolio().Balance(usd()); math.Abs(balance-4000) > epsilon {
+		t.Errorf("Expected the loss of the stop only, USD balance: %v", balance)
+	}
+}
@@ -0,0 +1,188 @@
+package exchange_test
+
+import (
+	goErrors "errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/quick-trade/xoney/common"
+	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/errors"
+	"github.com/quick-trade/xoney/exchange"
+)
+
+func bybitUSD() data.Currency {
+	return data.NewCurrency("USD", "BYBIT")
+}
+
+func bybitBTCUSD() data.Symbol {
+	return *data.NewSymbol("BTC", "USD", "BYBIT")
+}
+
+// multiExchangeSimulator holds 5000 USD on BINANCE and 1000 USD on BYBIT.
+func multiExchangeSimulator(t *testing.T, options ...exchange.MultiExchangeOption) *exchange.MultiExchangeSimulator {
+	t.Helper()
+
+	binance := marginSimulator()
+
+	bybitPortfolio := common.NewPortfolio(bybitUSD())
+	bybitPortfolio.Set(bybitUSD(), 1000)
+	bybit := exchange.NewMarginSimulator(bybitPortfolio, 0)
+
+	simulator, err := exchange.NewMultiExchangeSimulator(map[data.Exchange]exchange.Simulator{
+		"BINANCE": &binance,
+		"BYBIT":   &bybit,
+	}, options...)
+	if err != nil {
+		t.Fatalf("Error creating simulator: %v", err)
+	}
+
+	updateBTC(t, simulator, 50000, 50000, 50000, 50000, timeStart())
+	updateSymbol(t, simulator, bybitBTCUSD(), 50100, 50100, 50100, 50100, 0, timeStart())
+
+	return simulator
+}
+
+func TestMultiExchangeSimulator_RoutesOrdersByExchange(t *testing.T) {
+	simulator := multiExchangeSimulator(t)
+
+	order, _ := exchange.NewOrder(bybitBTCUSD(), exchange.Market, exchange.Buy, 50100, 0.01)
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Error placing order: %v", err)
+	}
+
+	portfolio := simulator.Portfolio()
+	if balance := portfolio.Balance(bybitUSD()); math.Abs(balance-499) > epsilon {
+		t.Errorf("Expected the order to be executed on BYBIT, USD balance: %v", balance)
+	}
+
+	if balance := portfolio.Balance(usd()); balance != 5000 {
+		t.Errorf("BINANCE balance must not change, got: %v", balance)
+	}
+
+	limit, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 49000, 0.01)
+	if err := simulator.PlaceOrder(*limit); err != nil {
+		t.Fatalf("Error placing order: %v", err)
+	}
+
+	// A candle of BYBIT must not execute orders on BINANCE.
+	updateSymbol(t, simulator, bybitBTCUSD(), 48000, 48000, 48000, 48000, 0, timeStart().Add(time.Hour))
+
+	if status, _ := simulator.OrderStatus(limit.ID()); status != exchange.Open {
+		t.Fatalf("Expected the BINANCE order to stay open, got: %v", status)
+	}
+
+	if err := simulator.CancelOrder(limit.ID()); err != nil {
+		t.Fatalf("Error cancelling order: %v", err)
+	}
+
+	if len(simulator.OpenOrders()) != 0 {
+		t.Errorf("Expected no open orders, got: %v", simulator.OpenOrders())
+	}
+
+	other, _ := exchange.NewOrder(*data.NewSymbol("BTC", "USD", "KRAKEN"), exchange.Market, exchange.Buy, 50000, 0.01)
+
+	var exchangeErr errors.UnknownExchangeError
+	if err := simulator.PlaceOrder(*other); !goErrors.As(err, &exchangeErr) {
+		t.Errorf("Expected UnknownExchangeError, got: %v", err)
+	}
+}
+
+func TestMultiExchangeSimulator_DelayedTransfer(t *testing.T) {
+	simulator := multiExchangeSimulator(t,
+		exchange.WithTransferRule(usd(), exchange.TransferRule{Fee: 1, Delay: 90 * time.Minute}),
+	)
+
+	if err := simulator.Transfer(1000, usd(), "BYBIT"); err != nil {
+		t.Fatalf("Error transferring funds: %v", err)
+	}
+
+	portfolio := simulator.Portfolio()
+	if portfolio.Balance(usd()) != 4000 || portfolio.Balance(bybitUSD()) != 1000 {
+		t.Fatalf("Expected the funds to be in flight, portfolio: %v", portfolio.Assets())
+	}
+
+	if total, _ := simulator.Total(); total != 5999 {
+		t.Errorf("Expected total balance 5999 including funds in flight, got: %v", total)
+	}
+
+	updateBTC(t, simulator, 50000, 50000, 50000, 50000, timeStart().Add(time.Hour))
+
+	if balance := simulator.Portfolio().Balance(bybitUSD()); balance != 1000 {
+		t.Fatalf("Transfer landed before the delay, BYBIT balance: %v", balance)
+	}
+
+	updateBTC(t, simulator, 50000, 50000, 50000, 50000, timeStart().Add(2*time.Hour))
+
+	if balance := simulator.Portfolio().Balance(bybitUSD()); balance != 1999 {
+		t.Errorf("Expected the transfer net of the fee to land, BYBIT balance: %v", balance)
+	}
+
+	if len(simulator.InFlight()) != 0 {
+		t.Errorf("Expected no transfers in flight, got: %v", simulator.InFlight())
+	}
+
+	if cost := simulator.Costs()[exchange.TransferCost]; cost != 1 {
+		t.Errorf("Expected transfer cost 1, got: %v", cost)
+	}
+}
+
+func TestMultiExchangeSimulator_InvalidTransfers(t *testing.T) {
+	simulator := multiExchangeSimulator(t,
+		exchange.WithTransferRule(usd(), exchange.TransferRule{Fee: 5}),
+	)
+
+	var feeErr errors.TransferFeeError
+	if err := simulator.Transfer(5, usd(), "BYBIT"); !goErrors.As(err, &feeErr) {
+		t.Errorf("Expected TransferFeeError, got: %v", err)
+	}
+
+	var fundsErr errors.NotEnoughFundsError
+	if err := simulator.Transfer(6000, usd(), "BYBIT"); !goErrors.As(err, &fundsErr) {
+		t.Errorf("Expected NotEnoughFundsError, got: %v", err)
+	}
+
+	var exchangeErr errors.UnknownExchangeError
+	if err := simulator.Transfer(100, usd(), "KRAKEN"); !goErrors.As(err, &exchangeErr) {
+		t.Errorf("Expected UnknownExchangeError, got: %v", err)
+	}
+
+	if total, _ := simulator.Total(); total != 6000 {
+		t.Errorf("Failed transfers must not change the balance, got: %v", total)
+	}
+
+	// Transfers without a delay land immediately.
+	if err := simulator.Transfer(100, usd(), "BYBIT"); err != nil {
+		t.Fatalf("Error transferring funds: %v", err)
+	}
+
+	if balance := simulator.Portfolio().Balance(bybitUSD()); balance != 1095 {
+		t.Errorf("Expected instant transfer, BYBIT balance: %v", balance)
+	}
+}
+
+func TestMultiExchangeSimulator_TransferFeeWithoutPrice(t *testing.T) {
+	eth := data.NewCurrency("ETH", "BINANCE")
+
+	portfolio := portfolioUSD()
+	portfolio.Set(eth, 2)
+	binance := exchange.NewMarginSimulator(portfolio, 0)
+	bybit := exchange.NewMarginSimulator(common.NewPortfolio(bybitUSD()), 0)
+
+	simulator, err := exchange.NewMultiExchangeSimulator(map[data.Exchange]exchange.Simulator{
+		"BINANCE": &binance,
+		"BYBIT":   &bybit,
+	}, exchange.WithTransferRule(eth, exchange.TransferRule{Fee: 0.01}))
+	if err != nil {
+		t.Fatalf("Error creating simulator: %v", err)
+	}
+
+	if err := simulator.Transfer(1, eth, "BYBIT"); err == nil {
+		t.Fatal("Expected an error valuing the fee without the price of ETH")
+	}
+
+	if balance := simulator.Portfolio().Balance(eth); balance != 2 {
+		t.Errorf("Failed transfer must not withdraw the funds, ETH balance: %v", balance)
+	}
+}
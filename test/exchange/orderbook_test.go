@@ -0,0 +1,263 @@
+package exchange_test
+
+import (
+	goErrors "errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/errors"
+	"github.com/quick-trade/xoney/events"
+	"github.com/quick-trade/xoney/exchange"
+)
+
+const bookRecord = `{"time":"1970-01-01T00:10:00Z","type":"snapshot","bids":[[99,3],[98,5]],"asks":[[100,1],[101,2]]}
+
+{"time":"1970-01-01T00:20:00Z","type":"diff","bids":[[99,1]],"asks":[[100,0],[102,4]]}
+{"time":"1970-01-01T01:10:00Z","type":"diff","bids":[[99,0]]}
+`
+
+func bookUpdates(t *testing.T, record string) []exchange.BookUpdate {
+	t.Helper()
+
+	updates, err := exchange.ReadBookUpdates(strings.NewReader(record))
+	if err != nil {
+		t.Fatalf("Error reading book updates: %v", err)
+	}
+
+	return updates
+}
+
+func bookSimulator(t *testing.T, record string) *exchange.OrderBookSimulator {
+	t.Helper()
+
+	books := map[data.Symbol][]exchange.BookUpdate{btcUSD(): bookUpdates(t, record)}
+
+	return exchange.NewOrderBookSimulator(portfolioUSD(), 0.001, books)
+}
+
+func TestReadBookUpdates(t *testing.T) {
+	updates := bookUpdates(t, bookRecord)
+
+	if len(updates) != 3 || !updates[0].Snapshot || updates[1].Snapshot {
+		t.Fatalf("Unexpected updates: %+v", updates)
+	}
+
+	book := exchange.NewOrderBook()
+	for _, update := range updates[:2] {
+		book.Apply(update)
+	}
+
+	asks := book.Asks()
+	if len(asks) != 2 || asks[0] != (exchange.BookLevel{Price: 101, Amount: 2}) || asks[1].Price != 102 {
+		t.Errorf("Unexpected asks after diff: %v", asks)
+	}
+
+	if mid, _ := book.Mid(); mid != 100 {
+		t.Errorf("Expected mid price 100, got: %v", mid)
+	}
+
+	if _, err := exchange.ReadBookUpdates(strings.NewReader(`{"type":"trade"}`)); err == nil {
+		t.Error("Expected error for unknown update type")
+	}
+}
+
+func TestOrderBookSimulator_MarketOrderOnEmptyBook(t *testing.T) {
+	simulator := bookSimulator(t, bookRecord)
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Market, exchange.Buy, 100, 1)
+
+	err := simulator.PlaceOrder(*order)
+	if !goErrors.As(err, &errors.EmptyBookError{}) {
+		t.Fatalf("Expected EmptyBookError before the first book update, got: %v", err)
+	}
+
+	if status, _ := simulator.OrderStatus(order.ID()); status != exchange.Rejected {
+		t.Errorf("Expected the order to be rejected, got: %v", status)
+	}
+
+	if len(simulator.Fills()) != 0 {
+		t.Errorf("Expected no fills, got: %+v", simulator.Fills())
+	}
+}
+
+func TestOrderBookSimulator_MarketOrderWalksTheBook(t *testing.T) {
+	simulator := bookSimulator(t, bookRecord)
+	updateBTC(t, simulator, 100, 100, 100, 100, timeStart().Add(15*time.Minute))
+
+	trade(t, simulator, exchange.Buy, 100, 2)
+
+	fills := simulator.Fills()
+	if len(fills) != 2 || fills[0].Price != 100 || fills[1].Price != 101 || fills[1].Amount != 1 {
+		t.Fatalf("Expected the order to take two levels, got: %+v", fills)
+	}
+
+	if fills[0].Liquidity != exchange.Taker {
+		t.Errorf("Market order must be a taker, got: %v", fills[0].Liquidity)
+	}
+
+	expected := 5000 - (100+101)*1.001
+	if balance := simulator.Portfolio().Balance(usd()); math.Abs(balance-expected) > epsilon {
+		t.Errorf("Expected USD balance %v, got: %v", expected, balance)
+	}
+
+	book, _ := simulator.Book(btcUSD())
+	if asks := book.Asks(); len(asks) != 1 || asks[0].Amount != 1 {
+		t.Errorf("Expected consumed liquidity to be removed, got: %v", asks)
+	}
+}
+
+func TestOrderBookSimulator_MarketOrderBeyondDepth(t *testing.T) {
+	simulator := bookSimulator(t, bookRecord)
+	updateBTC(t, simulator, 100, 100, 100, 100, timeStart().Add(15*time.Minute))
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Market, exchange.Sell, 99, 10)
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Error placing order: %v", err)
+	}
+
+	if balance := simulator.Portfolio().Balance(btc()); balance != -8 {
+		t.Errorf("Expected only the book depth to be sold, BTC balance: %v", balance)
+	}
+
+	updates := drainUpdates(&simulator.MarginSimulator)
+	if len(updates) != 2 || updates[0].Status != exchange.PartiallyFilled || updates[1].Status != exchange.Expired {
+		t.Errorf("Expected partial fill and expiration, got: %v", updates)
+	}
+}
+
+func TestOrderBookSimulator_MarketableOCOLegCancelsSiblings(t *testing.T) {
+	simulator := bookSimulator(t, bookRecord)
+	updateBTC(t, simulator, 100, 100, 100, 100, timeStart().Add(15*time.Minute))
+
+	marketable, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 100, 1)
+	resting, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 95, 1)
+
+	if err := events.NewOCO(*marketable, *resting).Occur(simulator); err != nil {
+		t.Fatalf("Error placing OCO orders: %v", err)
+	}
+
+	if status, _ := simulator.OrderStatus(resting.ID()); status != exchange.Canceled {
+		t.Errorf("Expected the resting order to be cancelled, got: %v", status)
+	}
+
+	if balance := simulator.Portfolio().Balance(btc()); balance != 1 {
+		t.Errorf("Expected only the marketable order to be executed, BTC balance: %v", balance)
+	}
+}
+
+func TestOrderBookSimulator_QueuePosition(t *testing.T) {
+	simulator := bookSimulator(t, bookRecord)
+	updateBTC(t, simulator, 100, 100, 100, 100, timeStart().Add(15*time.Minute))
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 99, 1)
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Error placing limit order: %v", err)
+	}
+
+	if queue, _ := simulator.QueuePosition(order.ID()); queue != 3 {
+		t.Fatalf("Expected the order to join the back of the queue, ahead: %v", queue)
+	}
+
+	// The best bid decreases from 3 to 1: two units ahead are executed.
+	updateBTC(t, simulator, 100, 100, 100, 100, timeStart().Add(time.Hour))
+
+	if queue, _ := simulator.QueuePosition(order.ID()); queue != 1 {
+		t.Fatalf("Expected one unit ahead in the queue, got: %v", queue)
+	}
+
+	if len(simulator.Fills()) != 0 {
+		t.Fatalf("Order must not be filled while the queue is ahead: %v", simulator.Fills())
+	}
+
+	// The last unit ahead is executed, the level is empty.
+	updateBTC(t, simulator, 100, 100, 100, 100, timeStart().Add(2*time.Hour))
+
+	if len(simulator.Fills()) != 0 {
+		t.Errorf("Order must not be filled by the executions ahead of it: %v", simulator.Fills())
+	}
+
+	if queue, _ := simulator.QueuePosition(order.ID()); queue != 0 {
+		t.Errorf("Expected the order to be first in the queue, got: %v", queue)
+	}
+}
+
+func TestOrderBookSimulator_RestingOrderFills(t *testing.T) {
+	record := `{"time":"1970-01-01T00:10:00Z","bids":[[100,1],[99,3]],"asks":[[101,2]]}
+{"time":"1970-01-01T00:20:00Z","type":"diff","bids":[[99,1]]}
+{"time":"1970-01-01T00:30:00Z","type":"diff","bids":[[100,0]]}
+{"time":"1970-01-01T00:40:00Z","type":"diff","bids":[[99,0]]}
+{"time":"1970-01-01T00:50:00Z","type":"diff","asks":[[101,0],[99,5]]}
+`
+	simulator := bookSimulator(t, record)
+	updateBTC(t, simulator, 100, 100, 100, 100, timeStart().Add(15*time.Minute))
+
+	first, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 99, 1)
+	second, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 99, 2)
+	placeAll(t, simulator, *first)
+
+	// A decrease below the best level is a cancellation: it shortens the queue only.
+	updateBTC(t, simulator, 100, 100, 100, 100, timeStart().Add(25*time.Minute))
+
+	if queue, _ := simulator.QueuePosition(first.ID()); queue != 1 || len(simulator.Fills()) != 0 {
+		t.Fatalf("Unexpected state after cancellation: queue %v, fills %v", queue, simulator.Fills())
+	}
+
+	placeAll(t, simulator, *second)
+
+	// The best level is emptied by executions of the orders ahead.
+	updateBTC(t, simulator, 100, 100, 100, 100, timeStart().Add(45*time.Minute))
+
+	for _, order := range []*exchange.Order{first, second} {
+		if queue, _ := simulator.QueuePosition(order.ID()); queue != 0 || len(simulator.Fills()) != 0 {
+			t.Fatalf("Expected orders at the front of the queue without fills, queue: %v", queue)
+		}
+	}
+
+	// The ask side reaches the price of the orders.
+	updateBTC(t, simulator, 100, 100, 100, 100, timeStart().Add(time.Hour))
+
+	fills := simulator.Fills()
+	if len(fills) != 2 {
+		t.Fatalf("Expected both orders to be filled, got: %+v", fills)
+	}
+
+	for _, fill := range fills {
+		if fill.Liquidity != exchange.Maker || fill.Price != 99 || !fill.Time.Equal(timeStart().Add(50*time.Minute)) {
+			t.Errorf("Expected maker fill at 99 at the moment of the update, got: %+v", fill)
+		}
+	}
+
+	if status, _ := simulator.OrderStatus(first.ID()); status != exchange.Filled {
+		t.Errorf("Expected the first order to be filled, got: %v", status)
+	}
+
+	if balance := simulator.Portfolio().Balance(btc()); balance != 3 {
+		t.Errorf("Expected both orders to be filled, BTC balance: %v", balance)
+	}
+}
+
+func TestOrderBookSimulator_FilledAfterQueueAhead(t *testing.T) {
+	record := `{"time":"1970-01-01T00:10:00Z","bids":[[99,2]],"asks":[[100,1]]}
+{"time":"1970-01-01T00:20:00Z","type":"diff","bids":[[99,5]]}
+{"time":"1970-01-01T00:30:00Z","type":"diff","bids":[[99,1]]}
+`
+	simulator := bookSimulator(t, record)
+	updateBTC(t, simulator, 100, 100, 100, 100, timeStart().Add(15*time.Minute))
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 99, 1.5)
+	placeAll(t, simulator, *order)
+
+	updateBTC(t, simulator, 100, 100, 100, 100, timeStart().Add(time.Hour))
+
+	fills := simulator.Fills()
+	if len(fills) != 1 || fills[0].Amount != 1.5 || fills[0].Liquidity != exchange.Maker {
+		t.Fatalf("Expected executions beyond the queue ahead to fill the order, got: %+v", fills)
+	}
+
+	if status, _ := simulator.OrderStatus(order.ID()); status != exchange.Filled {
+		t.Errorf("Expected the order to be filled, got: %v", status)
+	}
+}
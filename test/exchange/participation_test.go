@@ -0,0 +1,105 @@
+package exchange_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/quick-trade/xoney/exchange"
+)
+
+func TestMarginSimulator_PartialFillsAcrossCandles(t *testing.T) {
+	simulator := marginSimulator(exchange.WithMaxParticipation(0.1))
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 100, 3)
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Error placing limit order: %v", err)
+	}
+
+	updateSymbol(t, &simulator, btcUSD(), 100, 100, 100, 100, 20, timeStart())
+
+	if balance := simulator.Portfolio().Balance(btc()); balance != 2 {
+		t.Fatalf("Expected 10%% of candle volume to be filled, BTC balance: %v", balance)
+	}
+
+	updates := drainUpdates(&simulator)
+	if len(updates) != 1 || updates[0].Status != exchange.PartiallyFilled {
+		t.Fatalf("Expected partial execution to be reported, got: %v", updates)
+	}
+
+	if remaining := updates[0].Order.Remaining(); remaining != 1 {
+		t.Errorf("Expected remaining amount 1, got: %v", remaining)
+	}
+
+	updateSymbol(t, &simulator, btcUSD(), 100, 100, 100, 100, 20, timeStart().Add(time.Hour))
+
+	if balance := simulator.Portfolio().Balance(btc()); balance != 3 {
+		t.Errorf("Expected the order to be filled entirely, BTC balance: %v", balance)
+	}
+
+	updates = drainUpdates(&simulator)
+	if len(updates) != 1 || updates[0].Status != exchange.Filled {
+		t.Errorf("Expected the final execution to be reported, got: %v", updates)
+	}
+}
+
+func TestMarginSimulator_ParticipationSharedBetweenOrders(t *testing.T) {
+	simulator := marginSimulator(exchange.WithMaxParticipation(0.1))
+
+	for i := 0; i < 2; i++ {
+		order, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 100, 1.5)
+		if err := simulator.PlaceOrder(*order); err != nil {
+			t.Fatalf("Error placing limit order: %v", err)
+		}
+	}
+
+	updateSymbol(t, &simulator, btcUSD(), 100, 100, 100, 100, 20, timeStart())
+
+	if balance := simulator.Portfolio().Balance(btc()); math.Abs(balance-2) > epsilon {
+		t.Errorf("Expected orders to share the candle volume, BTC balance: %v", balance)
+	}
+}
+
+func TestMarginSimulator_FOK_KilledByVolume(t *testing.T) {
+	simulator := marginSimulator(exchange.WithMaxParticipation(0.1))
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 100, 3)
+	order.SetTimeInForce(exchange.FOK)
+
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Error placing FOK order: %v", err)
+	}
+
+	updateSymbol(t, &simulator, btcUSD(), 100, 100, 100, 100, 20, timeStart())
+
+	if balance := simulator.Portfolio().Balance(btc()); balance != 0 {
+		t.Errorf("FOK order must not be filled partially, BTC balance: %v", balance)
+	}
+
+	updates := drainUpdates(&simulator)
+	if len(updates) != 1 || updates[0].Status != exchange.Expired {
+		t.Errorf("Expected FOK order to expire, got: %v", updates)
+	}
+}
+
+func TestMarginSimulator_IOC_PartialFill(t *testing.T) {
+	simulator := marginSimulator(exchange.WithMaxParticipation(0.1))
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 100, 3)
+	order.SetTimeInForce(exchange.IOC)
+
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Error placing IOC order: %v", err)
+	}
+
+	updateSymbol(t, &simulator, btcUSD(), 100, 100, 100, 100, 20, timeStart())
+
+	if balance := simulator.Portfolio().Balance(btc()); balance != 2 {
+		t.Errorf("Expected IOC order to be filled partially, BTC balance: %v", balance)
+	}
+
+	updates := drainUpdates(&simulator)
+	if len(updates) != 2 || updates[0].Status != exchange.PartiallyFilled || updates[1].Status != exchange.Expired {
+		t.Errorf("Expected partial execution and expiration, got: %v", updates)
+	}
+}
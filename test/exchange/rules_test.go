@@ -0,0 +1,113 @@
+package exchange_test
+
+import (
+	goErrors "errors"
+	"math"
+	"testing"
+
+	"github.com/quick-trade/xoney/errors"
+	"github.com/quick-trade/xoney/exchange"
+)
+
+func btcRules() *exchange.RulesRegistry {
+	registry := exchange.NewRulesRegistry()
+	registry.Register(btcUSD(), exchange.TradingRules{TickSize: 0.5, LotSize: 0.001, MinNotional: 10})
+
+	return registry
+}
+
+func TestTradingRules_Validate(t *testing.T) {
+	registry := btcRules()
+
+	tests := []struct {
+		name   string
+		price  float64
+		amount float64
+		target error
+	}{
+		{"valid", 50000.5, 0.012, nil},
+		{"tick", 50000.25, 0.012, errors.NewPriceTickError(50000.25, 0.5)},
+		{"lot", 50000, 0.0125, errors.NewLotSizeError(0.0125, 0.001)},
+		{"notional", 5000, 0.001, errors.NewMinNotionalError(5, 10)},
+	}
+
+	for _, test := range tests {
+		order, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, test.price, test.amount)
+
+		err := registry.Validate(*order)
+		if test.target == nil && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+
+		if test.target != nil && !goErrors.Is(err, test.target) {
+			t.Errorf("%s: expected %v, got: %v", test.name, test.target, err)
+		}
+	}
+}
+
+func TestTradingRules_MarketOrderPriceNotChecked(t *testing.T) {
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Market, exchange.Buy, 50000.123, 0.01)
+
+	if err := btcRules().Validate(*order); err != nil {
+		t.Errorf("Market order price must not be checked against the tick size: %v", err)
+	}
+}
+
+func TestMarginSimulator_RejectsOrdersViolatingRules(t *testing.T) {
+	simulator := exchange.NewMarginSimulator(portfolioUSD(), 0, exchange.WithTradingRules(btcRules(), false))
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 49000, 0.0125)
+
+	var lotErr errors.LotSizeError
+	if err := simulator.PlaceOrder(*order); !goErrors.As(err, &lotErr) {
+		t.Fatalf("Expected LotSizeError, got: %v", err)
+	}
+
+	if status, _ := simulator.OrderStatus(order.ID()); status != exchange.Rejected {
+		t.Errorf("Expected the order to be rejected, got: %v", status)
+	}
+
+	if len(simulator.OpenOrders()) != 0 {
+		t.Error("Rejected order must not be placed")
+	}
+}
+
+func TestSpotSimulator_AutoRound(t *testing.T) {
+	simulator := exchange.NewSpotSimulator(portfolioUSD(), 0, exchange.WithTradingRules(btcRules(), true))
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 49000.3, 0.01234)
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Expected the order to be rounded, got: %v", err)
+	}
+
+	placed := simulator.OpenOrders()[0]
+	if placed.Price() != 49000.5 || math.Abs(placed.Amount()-0.012) > epsilon {
+		t.Errorf("Unexpected rounded order: price %v, amount %v", placed.Price(), placed.Amount())
+	}
+
+	tiny, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 49000, 0.0002)
+
+	var amountErr errors.InvalidOrderAmountError
+	if err := simulator.PlaceOrder(*tiny); !goErrors.As(err, &amountErr) {
+		t.Errorf("Expected the order rounded to zero to be rejected, got: %v", err)
+	}
+
+	small, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 5000, 0.0011)
+
+	var minErr errors.MinNotionalError
+	if err := simulator.PlaceOrder(*small); !goErrors.As(err, &minErr) {
+		t.Errorf("Expected MinNotionalError, got: %v", err)
+	}
+}
+
+func TestFuturesSimulator_TradingRules(t *testing.T) {
+	simulator := exchange.NewFuturesSimulator(portfolioUSD(), 0,
+		exchange.WithExecution(exchange.WithTradingRules(btcRules(), true)),
+	)
+
+	trade(t, simulator, exchange.Buy, 50000, 0.0199)
+
+	if size := simulator.Position(btcUSD()).Size; math.Abs(size-0.019) > epsilon {
+		t.Errorf("Expected the position amount to be rounded down to the lot size, got: %v", size)
+	}
+}
@@ -0,0 +1,78 @@
+package exchange_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/quick-trade/xoney/common/data"
+	"github.com/quick-trade/xoney/exchange"
+)
+
+const epsilon = 1e-9
+
+func TestSlippageModels(t *testing.T) {
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Market, exchange.Buy, 100, 25)
+	candle := *data.NewCandle(100, 110, 90, 100, 100, timeStart())
+
+	tests := []struct {
+		name     string
+		model    exchange.SlippageModel
+		expected float64
+	}{
+		{"none", exchange.NoSlippage{}, 0},
+		{"fixed", exchange.NewFixedSlippage(5), 0.0005},
+		{"volatility", exchange.NewVolatilitySlippage(0.5), 0.1},
+		{"volume", exchange.NewVolumeSlippage(1), 0.2 * 0.5},
+	}
+
+	for _, test := range tests {
+		if result := test.model.Slippage(*order, candle); math.Abs(result-test.expected) > epsilon {
+			t.Errorf("%s: expected slippage %v, got: %v", test.name, test.expected, result)
+		}
+	}
+}
+
+func TestMarginSimulator_MarketOrderSlippage(t *testing.T) {
+	simulator := exchange.NewMarginSimulator(portfolioUSD(), 0.001, exchange.WithSlippage(exchange.NewFixedSlippage(10)))
+	updateBTC(t, &simulator, 50000, 50000, 50000, 50000, timeStart())
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Market, exchange.Buy, 50000, 0.1)
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Error placing market order: %v", err)
+	}
+
+	fillPrice := 50000 * 1.001
+	expected := 5000 - 0.1*fillPrice*1.001
+
+	if balance := simulator.Portfolio().Balance(usd()); math.Abs(balance-expected) > epsilon {
+		t.Errorf("Expected USD balance %v, got: %v", expected, balance)
+	}
+
+	costs := simulator.Costs()
+	if math.Abs(costs[exchange.SlippageCost]-5) > epsilon {
+		t.Errorf("Expected slippage cost 5, got: %v", costs[exchange.SlippageCost])
+	}
+
+	if math.Abs(costs[exchange.CommissionCost]-0.1*fillPrice*0.001) > epsilon {
+		t.Errorf("Unexpected commission cost: %v", costs[exchange.CommissionCost])
+	}
+}
+
+func TestMarginSimulator_LimitOrderWithoutSlippage(t *testing.T) {
+	simulator := exchange.NewMarginSimulator(portfolioUSD(), 0, exchange.WithSlippage(exchange.NewFixedSlippage(10)))
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Sell, 50000, 0.1)
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Error placing limit order: %v", err)
+	}
+
+	updateBTC(t, &simulator, 49000, 51000, 48000, 50500, timeStart())
+
+	if balance := simulator.Portfolio().Balance(usd()); balance != 10000 {
+		t.Errorf("Expected limit order to be filled at its price, USD balance: %v", balance)
+	}
+
+	if cost := simulator.Costs()[exchange.SlippageCost]; cost != 0 {
+		t.Errorf("Expected no slippage cost for limit orders, got: %v", cost)
+	}
+}
@@ -0,0 +1,112 @@
+package exchange_test
+
+import (
+	goErrors "errors"
+	"testing"
+	"time"
+
+	"github.com/quick-trade/xoney/errors"
+	"github.com/quick-trade/xoney/exchange"
+)
+
+func TestMarginSimulator_OrderStatus(t *testing.T) {
+	simulator := marginSimulator()
+
+	filled, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 100, 1)
+	canceled, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 50, 1)
+	open, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Sell, 200, 1)
+
+	for _, order := range []*exchange.Order{filled, canceled, open} {
+		if err := simulator.PlaceOrder(*order); err != nil {
+			t.Fatalf("Error placing order: %v", err)
+		}
+	}
+
+	if orders := simulator.OpenOrders(); len(orders) != 3 {
+		t.Fatalf("Expected 3 open orders, got: %v", len(orders))
+	}
+
+	if err := simulator.CancelOrder(canceled.ID()); err != nil {
+		t.Fatalf("Error cancelling order: %v", err)
+	}
+
+	updateBTC(t, &simulator, 110, 120, 90, 100, timeStart())
+
+	expected := map[exchange.OrderID]exchange.OrderStatus{
+		filled.ID():   exchange.Filled,
+		canceled.ID(): exchange.Canceled,
+		open.ID():     exchange.Open,
+	}
+
+	for id, status := range expected {
+		result, err := simulator.OrderStatus(id)
+		if err != nil {
+			t.Errorf("Unexpected error getting order status: %v", err)
+		}
+
+		if result != status {
+			t.Errorf("Expected status %v for order %v, got: %v", status, id, result)
+		}
+	}
+
+	orders := simulator.OpenOrders()
+	if len(orders) != 1 || orders[0].ID() != open.ID() {
+		t.Errorf("Expected only one open order, got: %v", orders)
+	}
+}
+
+func TestMarginSimulator_OrderStatus_Unknown(t *testing.T) {
+	simulator := marginSimulator()
+
+	_, err := simulator.OrderStatus(42)
+	if !goErrors.Is(err, errors.NewUnknownOrderError(42)) {
+		t.Errorf("Expected UnknownOrderError, got: %v", err)
+	}
+}
+
+func TestMarginSimulator_FillStream(t *testing.T) {
+	simulator := marginSimulator()
+
+	first, _ := exchange.NewOrder(btcUSD(), exchange.Market, exchange.Buy, 100, 1)
+	if err := simulator.PlaceOrder(*first); err != nil {
+		t.Fatalf("Error placing order: %v", err)
+	}
+
+	fills := make([]exchange.Fill, 0)
+	for fill := range simulator.FillStream() {
+		fills = append(fills, fill)
+	}
+
+	if len(fills) != 1 || fills[0].OrderID != first.ID() {
+		t.Fatalf("Expected the first fill in the stream, got: %v", fills)
+	}
+
+	second, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Sell, 120, 1)
+	if err := simulator.PlaceOrder(*second); err != nil {
+		t.Fatalf("Error placing order: %v", err)
+	}
+
+	updateBTC(t, &simulator, 110, 130, 100, 125, timeStart().Add(time.Hour))
+
+	fills = fills[:0]
+	for fill := range simulator.FillStream() {
+		fills = append(fills, fill)
+	}
+
+	if len(fills) != 1 || fills[0].OrderID != second.ID() {
+		t.Errorf("Expected only the new fill in the stream, got: %v", fills)
+	}
+}
+
+func TestSpotSimulator_RejectedOrderStatus(t *testing.T) {
+	simulator := spotSimulator()
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Market, exchange.Buy, 50000, 1)
+	if err := simulator.PlaceOrder(*order); err == nil {
+		t.Fatal("Expected order to be rejected")
+	}
+
+	if status, _ := simulator.OrderStatus(order.ID()); status != exchange.Rejected {
+		t.Errorf("Expected rejected status, got: %v", status)
+	}
+}
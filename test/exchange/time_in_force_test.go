@@ -0,0 +1,108 @@
+package exchange_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/quick-trade/xoney/exchange"
+)
+
+func drainUpdates(simulator *exchange.MarginSimulator) []exchange.OrderUpdate {
+	updates := make([]exchange.OrderUpdate, 0)
+	for update := range simulator.OrderUpdates() {
+		updates = append(updates, update)
+	}
+
+	return updates
+}
+
+func TestNewOrder_DefaultTimeInForce(t *testing.T) {
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 100, 1)
+
+	if order.TimeInForce() != exchange.GTC {
+		t.Errorf("Expected GTC by default, got: %v", order.TimeInForce())
+	}
+}
+
+func TestMarginSimulator_IOC_ExpiresIfNotFilled(t *testing.T) {
+	simulator := marginSimulator()
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 49000, 0.1)
+	order.SetTimeInForce(exchange.IOC)
+
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Error placing IOC order: %v", err)
+	}
+
+	updateBTC(t, &simulator, 50000, 50500, 49500, 50000, timeStart())
+
+	updates := drainUpdates(&simulator)
+	if len(updates) != 1 || updates[0].Status != exchange.Expired || updates[0].Order.ID() != order.ID() {
+		t.Fatalf("Expected expiration of the IOC order to be reported, got: %v", updates)
+	}
+
+	updateBTC(t, &simulator, 50000, 50500, 48000, 48500, timeStart().Add(time.Hour))
+
+	if balance := simulator.Portfolio().Balance(btc()); balance != 0 {
+		t.Errorf("Expired IOC order must not be executed, BTC balance: %v", balance)
+	}
+
+	if updates := drainUpdates(&simulator); len(updates) != 0 {
+		t.Errorf("Expected updates to be consumed, got: %v", updates)
+	}
+}
+
+func TestMarginSimulator_FOK_FilledWithinCandle(t *testing.T) {
+	simulator := marginSimulator()
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 49000, 0.1)
+	order.SetTimeInForce(exchange.FOK)
+
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Error placing FOK order: %v", err)
+	}
+
+	updateBTC(t, &simulator, 50000, 50500, 48500, 49500, timeStart())
+
+	if balance := simulator.Portfolio().Balance(btc()); balance != 0.1 {
+		t.Errorf("Expected FOK order to be filled, BTC balance: %v", balance)
+	}
+
+	for _, update := range drainUpdates(&simulator) {
+		if update.Status == exchange.Expired {
+			t.Errorf("Filled FOK order must not expire")
+		}
+	}
+}
+
+func TestMarginSimulator_GTD_Expiration(t *testing.T) {
+	simulator := marginSimulator()
+
+	order, _ := exchange.NewOrder(btcUSD(), exchange.Limit, exchange.Buy, 49000, 0.1)
+	order.ExpireAt(timeStart().Add(90 * time.Minute))
+
+	if err := simulator.PlaceOrder(*order); err != nil {
+		t.Fatalf("Error placing GTD order: %v", err)
+	}
+
+	updateBTC(t, &simulator, 50000, 50500, 49500, 50000, timeStart().Add(time.Hour))
+
+	if updates := drainUpdates(&simulator); len(updates) != 0 {
+		t.Fatalf("GTD order expired too early: %v", updates)
+	}
+
+	updateBTC(t, &simulator, 50000, 50500, 49500, 50000, timeStart().Add(2*time.Hour))
+
+	updates := drainUpdates(&simulator)
+	if len(updates) != 1 || updates[0].Status != exchange.Expired {
+		t.Fatalf("Expected expiration of the GTD order, got: %v", updates)
+	}
+
+	if !updates[0].Time.Equal(timeStart().Add(2 * time.Hour)) {
+		t.Errorf("Unexpected expiration time: %v", updates[0].Time)
+	}
+
+	if err := simulator.CancelOrder(order.ID()); err == nil {
+		t.Error("Expected expired order to be removed")
+	}
+}
@@ -174,6 +174,22 @@ func (m *MockConnector) GetPrices(symbols []data.Symbol) (<-chan exchange.Symbol
 	return priceChan, errChan
 }
 
+func (m *MockConnector) OpenOrders() []exchange.Order {
+	return nil
+}
+
+func (m *MockConnector) OrderStatus(id exchange.OrderID) (exchange.OrderStatus, error) {
+	return exchange.Open, nil
+}
+
+func (m *MockConnector) FillStream() <-chan exchange.Fill {
+	return nil
+}
+
+func (m *MockConnector) OrderUpdates() <-chan exchange.OrderUpdate {
+	return nil
+}
+
 func (m *MockConnector) SetPrice(symbol data.Symbol, price float64) {
 	m.prices[symbol] = price
 }
@@ -5,6 +5,7 @@ import (
 	"math"
 	"time"
 
+	"github.com/quick-trade/xoney/common"
 	"github.com/quick-trade/xoney/common/data"
 	"github.com/quick-trade/xoney/errors"
 	"github.com/quick-trade/xoney/events"
@@ -28,7 +29,7 @@ func NewGridLevel(price, amount float64) (*GridLevel, error) {
 	return &GridLevel{
 		price:  price,
 		amount: amount,
-		id:     LevelID(internal.RandomUint64()),
+		id:     LevelID(common.NextID()),
 	}, nil
 }
 
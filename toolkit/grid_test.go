@@ -158,6 +158,22 @@ func (m *mockConnector) GetPrices(symbols []data.Symbol) (<-chan exchange.Symbol
 	return nil, nil
 }
 
+func (m *mockConnector) OpenOrders() []exchange.Order {
+	return nil
+}
+
+func (m *mockConnector) OrderStatus(id exchange.OrderID) (exchange.OrderStatus, error) {
+	return exchange.Open, nil
+}
+
+func (m *mockConnector) FillStream() <-chan exchange.Fill {
+	return nil
+}
+
+func (m *mockConnector) OrderUpdates() <-chan exchange.OrderUpdate {
+	return nil
+}
+
 func TestGridBot_Next(t *testing.T) {
 	generator := &MockGridGenerator{counter: 0}
 
@@ -3,6 +3,7 @@ package toolkit
 import (
 	"fmt"
 	"math"
+	"sort"
 	"sync"
 
 	"github.com/quick-trade/xoney/common"
@@ -136,7 +137,14 @@ func (r *RebalancePortfolio) rebalance(target common.BaseDistribution) (events.E
 func (r *RebalancePortfolio) newOrders(differences common.BaseDistribution) (events.Event, error) {
 	Events := make([]events.Event, 0, len(differences))
 
-	for currency, amount := range differences {
+	// Orders are created in the order of the currency names to keep their IDs reproducible.
+	currencies := internal.MapKeys(differences)
+	sort.Slice(currencies, func(i, j int) bool {
+		return currencies[i].String() < currencies[j].String()
+	})
+
+	for _, currency := range currencies {
+		amount := differences[currency]
 		if amount == 0 {
 			continue
 		}